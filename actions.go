@@ -2,11 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"time"
 
 	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
 	"github.com/pkg/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ActionType string
@@ -24,35 +25,270 @@ const (
 	ActionDeletePVC ActionType = "DeletePVC"
 
 	ActionKillNode ActionType = "KillNode"
+
+	ActionTypeUncordonNode ActionType = "UncordonNode"
+	ActionTypeRestoreNode  ActionType = "RestoreNode"
+	ActionTypeWait         ActionType = "Wait"
+	ActionTypeZoneOutage   ActionType = "ZoneOutage"
+	ActionTypePartition    ActionType = "Partition"
+	ActionTypeNetem        ActionType = "Netem"
+	ActionTypeFreeze       ActionType = "Freeze"
+	ActionTypeKillProcess  ActionType = "KillProcess"
+	ActionTypeDiskFull     ActionType = "DiskFull"
+
+	ActionTypeRepeat   ActionType = "Repeat"
+	ActionTypeParallel ActionType = "Parallel"
+	ActionTypeChoose   ActionType = "Choose"
+	ActionTypeSequence ActionType = "Sequence"
 )
 
 type ActionCreateDeploymentDescription struct {
-	Spec arangoapi.DeploymentSpec
+	Name string                   `json:"name"`
+	Spec arangoapi.DeploymentSpec `json:"spec"`
+}
+
+type ActionDeleteDeploymentDescription struct {
+	Name string `json:"name"`
 }
 
 type ActionDeployOperatorDescription struct {
-	Image string
+	Image string `json:"image"`
+}
+
+type ActionDeleteOperatorDescription struct {
+	DeleteCRD bool `json:"deleteCRD"`
 }
 
 type ActionDeletePodDescription struct {
 	Target            PodTarget `json:"target"`
 	WaitForCompletion bool      `json:"waitForCompletion"`
+	GracePeriod       *int64    `json:"gracePeriod,omitempty"`
+}
+
+// ActionEvictPodDescription evicts a pod. An eviction blocked by a
+// PodDisruptionBudget for longer than Timeout (5m if unset) fails, or force
+// deletes the pod if ForceDelete is set. ExpectBlocked turns a blocked
+// eviction into the expected result.
+type ActionEvictPodDescription struct {
+	Target            PodTarget `json:"target"`
+	WaitForCompletion bool      `json:"waitForCompletion"`
+	Timeout           Duration  `json:"timeout,omitempty"`
+	ForceDelete       bool      `json:"forceDelete"`
+	ExpectBlocked     bool      `json:"expectBlocked"`
+}
+
+type ActionDrainNodeDescription struct {
+	Target          NodeTarget `json:"target"`
+	DeleteLocalData bool       `json:"deleteLocalData"`
+	GracePeriod     *int64     `json:"gracePeriod,omitempty"`
+	Timeout         Duration   `json:"timeout,omitempty"`
+	MaxParallel     int        `json:"maxParallel,omitempty"`
+	// Uncordon makes the node schedulable again once the drain completed
+	Uncordon bool `json:"uncordon"`
+}
+
+type ActionDeletePVCDescription struct {
+	Target          PodTarget `json:"target"`
+	DeletePod       bool      `json:"deletePod"`
+	RemoveFinalizer bool      `json:"removeFinalizer"`
+	WaitForRecovery bool      `json:"waitForRecovery"`
+}
+
+type ActionKillNodeDescription struct {
+	Target NodeTarget `json:"target"`
+}
+
+type ActionUncordonNodeDescription struct {
+	Target NodeTarget `json:"target"`
+}
+
+// ActionRestoreNodeDescription brings back a node killed by KillNode
+type ActionRestoreNodeDescription struct {
+	Target NodeTarget `json:"target"`
+}
+
+type ZoneOutageMode string
+
+const (
+	ZoneOutageKill  ZoneOutageMode = "Kill"
+	ZoneOutageDrain ZoneOutageMode = "Drain"
+)
+
+// defaultZoneLabel is the well-known label holding the zone of a node
+const defaultZoneLabel = "topology.kubernetes.io/zone"
+
+// ActionZoneOutageDescription kills or drains all usable nodes of a zone
+// at once and restores them after the outage. Nodes overrides the zone
+// with an explicit list of nodes.
+type ActionZoneOutageDescription struct {
+	Zone      string         `json:"zone,omitempty"`
+	ZoneLabel string         `json:"zoneLabel,omitempty"`
+	Nodes     []string       `json:"nodes,omitempty"`
+	Mode      ZoneOutageMode `json:"mode"`
+	Outage    Duration       `json:"outage"`
+}
+
+// ActionPartitionDescription cuts the network between the target pod and
+// the members of the From groups of its deployment for the given duration.
+// All other members of the deployment are cut off if From is empty.
+type ActionPartitionDescription struct {
+	Target   PodTarget  `json:"target"`
+	From     []PodGroup `json:"from,omitempty"`
+	Duration Duration   `json:"duration"`
+}
+
+// ActionNetemDescription adds latency, jitter and packet loss to the network
+// of the target pod for the given duration. Loss is a percentage. With a
+// helper image tc runs in a privileged helper pod on the node of the
+// target, otherwise in the arangod container, which then needs tc and the
+// NET_ADMIN capability.
+type ActionNetemDescription struct {
+	Target      PodTarget `json:"target"`
+	Latency     Duration  `json:"latency,omitempty"`
+	Jitter      Duration  `json:"jitter,omitempty"`
+	Loss        float64   `json:"loss,omitempty"`
+	HelperImage string    `json:"helperImage,omitempty"`
+	Duration    Duration  `json:"duration"`
+}
+
+// ActionFreezeDescription stops the arangod process of the target pod with
+// SIGSTOP for the given duration, keeping its connections open, and then
+// continues it with SIGCONT. The signals are sent by a helper pod on the
+// node of the target, using busybox unless HelperImage is set.
+type ActionFreezeDescription struct {
+	Target      PodTarget `json:"target"`
+	HelperImage string    `json:"helperImage,omitempty"`
+	Duration    Duration  `json:"duration"`
+}
+
+// ActionKillProcessDescription kills the arangod process of the target pod
+// with the signal, so the kubelet restarts the container within the same
+// pod, and waits up to the timeout until the container runs again. SIGKILL
+// is sent by a helper pod, using busybox unless HelperImage is set.
+type ActionKillProcessDescription struct {
+	Target      PodTarget     `json:"target"`
+	Signal      ProcessSignal `json:"signal"`
+	HelperImage string        `json:"helperImage,omitempty"`
+	Timeout     Duration      `json:"timeout,omitempty"`
+}
+
+// ActionDiskFullDescription fills the data volume of the target pod to the
+// percentage of its size, 100 filling it completely, holds it for the
+// duration and frees the space again
+type ActionDiskFullDescription struct {
+	Target   PodTarget `json:"target"`
+	Percent  int       `json:"percent"`
+	Duration Duration  `json:"duration"`
+}
+
+type ActionWaitDescription struct {
+	Duration Duration `json:"duration"`
+}
+
+// ActionRepeatDescription runs its actions repeatedly until Count iterations
+// are done or Duration has passed, whichever comes first
+type ActionRepeatDescription struct {
+	Count    int                   `json:"count,omitempty"`
+	Duration Duration              `json:"duration,omitempty"`
+	Actions  ActionDescriptionList `json:"actions"`
+}
+
+// ActionParallelDescription runs all its actions concurrently
+type ActionParallelDescription struct {
+	Actions ActionDescriptionList `json:"actions"`
+}
+
+// ActionChoice is one weighted alternative of a Choose block
+type ActionChoice struct {
+	Weight  int                   `json:"weight"`
+	Actions ActionDescriptionList `json:"actions"`
+}
+
+type ActionChoiceList []ActionChoice
+
+// ActionChooseDescription runs one of its choices, picked at random
+// according to the weights
+type ActionChooseDescription struct {
+	Choices ActionChoiceList `json:"choices"`
 }
 
+// ActionSequenceDescription runs its actions in order. It is mostly
+// useful as a single branch of a Parallel block.
+type ActionSequenceDescription struct {
+	Actions ActionDescriptionList `json:"actions"`
+}
+
+// ActionDescription describes a single action of a script. The type
+// specific payload is stored inline next to the common fields, see
+// UnmarshalJSON for the decoding rules.
 type ActionDescription struct {
 	Type          ActionType    `json:"action"`
 	WaitForHealth bool          `json:"waitForHealth"`
 	Delay         time.Duration `json:"delay"`
 
-	CreateDeployment *ActionCreateDeploymentDescription `json:inline`
-	DeployOperator   *ActionDeployOperatorDescription   `json:inline`
+	CreateDeployment *ActionCreateDeploymentDescription `json:"-"`
+	DeleteDeployment *ActionDeleteDeploymentDescription `json:"-"`
+	DeployOperator   *ActionDeployOperatorDescription   `json:"-"`
+	DeleteOperator   *ActionDeleteOperatorDescription   `json:"-"`
+	DeletePod        *ActionDeletePodDescription        `json:"-"`
+	EvictPod         *ActionEvictPodDescription         `json:"-"`
+	DrainNode        *ActionDrainNodeDescription        `json:"-"`
+	DeletePVC        *ActionDeletePVCDescription        `json:"-"`
+	KillNode         *ActionKillNodeDescription         `json:"-"`
+	UncordonNode     *ActionUncordonNodeDescription     `json:"-"`
+	RestoreNode      *ActionRestoreNodeDescription      `json:"-"`
+	Wait             *ActionWaitDescription             `json:"-"`
+	ZoneOutage       *ActionZoneOutageDescription       `json:"-"`
+	Partition        *ActionPartitionDescription        `json:"-"`
+	Netem            *ActionNetemDescription            `json:"-"`
+	Freeze           *ActionFreezeDescription           `json:"-"`
+	KillProcess      *ActionKillProcessDescription      `json:"-"`
+	DiskFull         *ActionDiskFullDescription         `json:"-"`
+	Repeat           *ActionRepeatDescription           `json:"-"`
+	Parallel         *ActionParallelDescription         `json:"-"`
+	Choose           *ActionChooseDescription           `json:"-"`
+	Sequence         *ActionSequenceDescription         `json:"-"`
 }
 
 type ActionInterface interface {
 	Nodes() NodeManager
 	Pods() PodManager
 	Deployment() DeploymentManager
-	ErrorChannel() chan error
+	Operator() Operator
+	// Cleanups keeps the cleanups of running actions, which are run if
+	// the agent stops before the actions end
+	Cleanups() *CleanupRegistry
+	// WaitForHealth waits until all deployments are ready
+	WaitForHealth(ctx context.Context) error
+
+	// Now returns the current time of the environment
+	Now() time.Time
+	// Sleep waits for the given duration or until the context is done
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// branchingEnvironment is implemented by environments keeping state per
+// concurrent branch of actions, like the virtual clock of a dry run
+type branchingEnvironment interface {
+	// Fork returns the environment of a new concurrent branch
+	Fork() ActionInterface
+	// Join merges the state of the completed branches
+	Join(branches []ActionInterface)
+}
+
+// forkEnvironment returns the environment to run a concurrent branch in
+func forkEnvironment(iface ActionInterface) ActionInterface {
+	if b, ok := iface.(branchingEnvironment); ok {
+		return b.Fork()
+	}
+	return iface
+}
+
+// joinEnvironment merges the branches created by forkEnvironment
+func joinEnvironment(iface ActionInterface, branches []ActionInterface) {
+	if b, ok := iface.(branchingEnvironment); ok {
+		b.Join(branches)
+	}
 }
 
 type Action interface {
@@ -62,32 +298,81 @@ type Action interface {
 type ActionDescriptionList []ActionDescription
 
 type ActionScript struct {
-	Cluster ClusterConfig         `json:"clusterConfig"`
+	Cluster ClusterConfig `json:"clusterConfig"`
+	// Seed is the random seed of the run the script was recorded from
+	Seed    int64                 `json:"seed,omitempty"`
 	Actions ActionDescriptionList `json:"actions"`
 }
 
-func NewAction(desc ActionDescription) (Action, error) {
-	return nil, nil
+// actionConstructor creates an action from its description
+type actionConstructor func(desc ActionDescription) (Action, error)
+
+// actionRegistry maps every known action type to its constructor
+var actionRegistry = map[ActionType]actionConstructor{
+	ActionTypeCreateDeployment: newActionCreateDeployment,
+	ActionTypeDeleteDeployment: newActionDeleteDeployment,
+	ActionTypeDeployOperator:   newActionDeployOperator,
+	ActionTypeDeleteOperator:   newActionDeleteOperator,
+	ActionTypeDeletePod:        newActionDeletePod,
+	ActionTypeEvictPod:         newActionEvictPod,
+	ActionTypeDrainNode:        newActionDrainNode,
+	ActionDeletePVC:            newActionDeletePVC,
+	ActionKillNode:             newActionKillNode,
+	ActionTypeUncordonNode:     newActionUncordonNode,
+	ActionTypeRestoreNode:      newActionRestoreNode,
+	ActionTypeWait:             newActionWait,
+	ActionTypeZoneOutage:       newActionZoneOutage,
+	ActionTypePartition:        newActionPartition,
+	ActionTypeNetem:            newActionNetem,
+	ActionTypeFreeze:           newActionFreeze,
+	ActionTypeKillProcess:      newActionKillProcess,
+	ActionTypeDiskFull:         newActionDiskFull,
 }
 
-type actionDeletePod struct {
-	target            PodTarget
-	waitForCompletion bool
+func init() {
+	// Blocks create their child actions using NewAction and can therefore
+	// not be part of the initializer of the registry
+	actionRegistry[ActionTypeRepeat] = newActionRepeat
+	actionRegistry[ActionTypeParallel] = newActionParallel
+	actionRegistry[ActionTypeChoose] = newActionChoose
+	actionRegistry[ActionTypeSequence] = newActionSequence
 }
 
-func newActionDeletePod(desc ActionDeletePodDescription) Action {
-	return &actionDeletePod{
-		target:            desc.Target,
-		waitForCompletion: desc.WaitForCompletion,
+// NewAction creates the action described by desc. Unknown action types
+// and descriptions without the payload required by their type are rejected.
+func NewAction(desc ActionDescription) (Action, error) {
+	constructor, ok := actionRegistry[desc.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown action type %q", desc.Type)
+	}
+
+	action, err := constructor(desc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create action %s", desc.Type)
 	}
+
+	return action, nil
 }
 
-func newErrorChannelOrDefault(iface ActionInterface, new bool) chan error {
-	if new {
-		return make(chan error)
-	}
+func errMissingDescription(field string) error {
+	return fmt.Errorf("missing %s description", field)
+}
 
-	return iface.ErrorChannel()
+// newCompletionChannel returns a channel receiving the result of an
+// asynchronous operation. The channel is buffered, so the sender never
+// blocks even if the caller stopped waiting.
+func newCompletionChannel() chan error {
+	return make(chan error, 1)
+}
+
+// logCompletion logs the failure of an asynchronous operation the action
+// does not wait for
+func logCompletion(operation string, completion <-chan error) {
+	go func() {
+		if err := <-completion; err != nil {
+			log.Printf("%s failed: %s", operation, err.Error())
+		}
+	}()
 }
 
 func waitForCompletion(ctx context.Context, completed <-chan error) error {
@@ -101,19 +386,3 @@ func waitForCompletion(ctx context.Context, completed <-chan error) error {
 		return ctx.Err()
 	}
 }
-
-func (a *actionDeletePod) Run(ctx context.Context, iface ActionInterface) error {
-
-	options := metav1.DeleteOptions{}
-	channel := newErrorChannelOrDefault(iface, a.waitForCompletion)
-	if err := iface.Pods().Target(a.target).Delete(ctx, channel, &options); err != nil {
-		return nil
-	}
-
-	if a.waitForCompletion {
-		defer close(channel)
-		waitForCompletion(ctx, channel)
-	}
-
-	return nil
-}
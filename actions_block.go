@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type actionRepeat struct {
+	count    int
+	duration time.Duration
+	steps    []actionStep
+}
+
+func newActionRepeat(desc ActionDescription) (Action, error) {
+	if desc.Repeat == nil {
+		return nil, errMissingDescription("repeat")
+	}
+
+	steps, err := newActionSteps("actions", desc.Repeat.Actions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &actionRepeat{
+		count:    desc.Repeat.Count,
+		duration: time.Duration(desc.Repeat.Duration),
+		steps:    steps,
+	}, nil
+}
+
+func (a *actionRepeat) Run(ctx context.Context, iface ActionInterface) error {
+	var deadline time.Time
+	if a.duration > 0 {
+		deadline = iface.Now().Add(a.duration)
+	}
+
+	for i := 0; a.count == 0 || i < a.count; i++ {
+		if !deadline.IsZero() && iface.Now().After(deadline) {
+			log.Printf("Repeat duration of %s reached after %d iterations", a.duration, i)
+			return nil
+		}
+
+		log.Printf("Starting iteration %d of repeat", i)
+		if err := runActionSteps(ctx, iface, fmt.Sprintf("repeat[%d].actions", i), a.steps); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type actionParallel struct {
+	steps []actionStep
+}
+
+func newActionParallel(desc ActionDescription) (Action, error) {
+	if desc.Parallel == nil {
+		return nil, errMissingDescription("parallel")
+	}
+
+	steps, err := newActionSteps("actions", desc.Parallel.Actions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &actionParallel{
+		steps: steps,
+	}, nil
+}
+
+// Run starts all actions concurrently and waits for all of them to complete.
+// The first error is returned.
+func (a *actionParallel) Run(ctx context.Context, iface ActionInterface) error {
+	// The first failing branch cancels its siblings
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	completion := make(chan error, len(a.steps))
+	branches := make([]ActionInterface, len(a.steps))
+
+	for i, step := range a.steps {
+		branches[i] = forkEnvironment(iface)
+		go func(i int, step actionStep) {
+			completion <- step.run(ctx, branches[i], fmt.Sprintf("parallel.actions[%d]", i))
+		}(i, step)
+	}
+
+	// Wait for all branches, also after cancellation, so none is still
+	// running when the branches are joined
+	var result error
+	for range a.steps {
+		if err := <-completion; err != nil && result == nil {
+			result = err
+			cancel()
+		}
+	}
+
+	joinEnvironment(iface, branches)
+	return result
+}
+
+type actionChoose struct {
+	weights []int
+	choices [][]actionStep
+}
+
+func newActionChoose(desc ActionDescription) (Action, error) {
+	if desc.Choose == nil {
+		return nil, errMissingDescription("choose")
+	}
+
+	action := &actionChoose{}
+	for i, choice := range desc.Choose.Choices {
+		steps, err := newActionSteps(fmt.Sprintf("choices[%d].actions", i), choice.Actions)
+		if err != nil {
+			return nil, err
+		}
+		action.weights = append(action.weights, choice.Weight)
+		action.choices = append(action.choices, steps)
+	}
+
+	return action, nil
+}
+
+// pick returns the index of a random choice according to the weights
+func (a *actionChoose) pick() (int, error) {
+	total := 0
+	for _, w := range a.weights {
+		total += w
+	}
+	if total <= 0 {
+		return 0, errors.New("no choice with positive weight")
+	}
+
+	n := rand.Intn(total)
+	for i, w := range a.weights {
+		if n < w {
+			return i, nil
+		}
+		n -= w
+	}
+	return len(a.weights) - 1, nil
+}
+
+func (a *actionChoose) Run(ctx context.Context, iface ActionInterface) error {
+	i, err := a.pick()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Chose alternative %d", i)
+	return runActionSteps(ctx, iface, fmt.Sprintf("choices[%d].actions", i), a.choices[i])
+}
+
+type actionSequence struct {
+	steps []actionStep
+}
+
+func newActionSequence(desc ActionDescription) (Action, error) {
+	if desc.Sequence == nil {
+		return nil, errMissingDescription("sequence")
+	}
+
+	steps, err := newActionSteps("actions", desc.Sequence.Actions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &actionSequence{
+		steps: steps,
+	}, nil
+}
+
+func (a *actionSequence) Run(ctx context.Context, iface ActionInterface) error {
+	return runActionSteps(ctx, iface, "sequence.actions", a.steps)
+}
+
+type actionWait struct {
+	duration time.Duration
+}
+
+func newActionWait(desc ActionDescription) (Action, error) {
+	if desc.Wait == nil {
+		return nil, errMissingDescription("wait")
+	}
+
+	return &actionWait{
+		duration: time.Duration(desc.Wait.Duration),
+	}, nil
+}
+
+func (a *actionWait) Run(ctx context.Context, iface ActionInterface) error {
+	log.Printf("Waiting %s", a.duration)
+	return iface.Sleep(ctx, a.duration)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+)
+
+type actionCreateDeployment struct {
+	name string
+	spec arangoapi.DeploymentSpec
+}
+
+func newActionCreateDeployment(desc ActionDescription) (Action, error) {
+	if desc.CreateDeployment == nil {
+		return nil, errMissingDescription("createDeployment")
+	}
+
+	return &actionCreateDeployment{
+		name: desc.CreateDeployment.Name,
+		spec: desc.CreateDeployment.Spec,
+	}, nil
+}
+
+func (a *actionCreateDeployment) Run(ctx context.Context, iface ActionInterface) error {
+	if err := iface.Deployment().Allowed(a.name); err != nil {
+		return err
+	}
+
+	_, err := iface.Deployment().New(ctx, a.name, a.spec)
+	return err
+}
+
+type actionDeleteDeployment struct {
+	name string
+}
+
+func newActionDeleteDeployment(desc ActionDescription) (Action, error) {
+	if desc.DeleteDeployment == nil {
+		return nil, errMissingDescription("deleteDeployment")
+	}
+
+	return &actionDeleteDeployment{
+		name: desc.DeleteDeployment.Name,
+	}, nil
+}
+
+func (a *actionDeleteDeployment) Run(ctx context.Context, iface ActionInterface) error {
+	if err := iface.Deployment().Allowed(a.name); err != nil {
+		return err
+	}
+
+	return iface.Deployment().Deployment(a.name).Delete(ctx)
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scriptError reports an error at a path inside an action script
+type scriptError struct {
+	path string
+	err  error
+}
+
+func (e *scriptError) Error() string {
+	return e.path + ": " + e.err.Error()
+}
+
+// withPath prefixes the path of err with the given element
+func withPath(element string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if se, ok := err.(*scriptError); ok {
+		if strings.HasPrefix(se.path, "[") {
+			return &scriptError{path: element + se.path, err: se.err}
+		}
+		return &scriptError{path: element + "." + se.path, err: se.err}
+	}
+
+	return &scriptError{path: element, err: err}
+}
+
+// actionPayload is the type specific part of an action description
+type actionPayload interface {
+	Validate() error
+}
+
+// actionPayloadFields maps each action type with a payload to the index of
+// its payload field in ActionDescription. Payload fields are named after their
+// action type.
+var actionPayloadFields = payloadFields()
+
+func payloadFields() map[ActionType]int {
+	payloadType := reflect.TypeOf((*actionPayload)(nil)).Elem()
+	descType := reflect.TypeOf(ActionDescription{})
+
+	fields := make(map[ActionType]int)
+	for i := 0; i < descType.NumField(); i++ {
+		if field := descType.Field(i); field.Type.Implements(payloadType) {
+			fields[ActionType(field.Name)] = i
+		}
+	}
+	return fields
+}
+
+// payloadField returns the payload field of the description, false if the
+// action type has no payload
+func (desc *ActionDescription) payloadField() (reflect.Value, bool) {
+	index, ok := actionPayloadFields[desc.Type]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return reflect.ValueOf(desc).Elem().Field(index), true
+}
+
+// newPayload allocates the payload of the description or returns nil if the
+// action type has no payload
+func (desc *ActionDescription) newPayload() actionPayload {
+	field, ok := desc.payloadField()
+	if !ok {
+		return nil
+	}
+	field.Set(reflect.New(field.Type().Elem()))
+	return field.Interface().(actionPayload)
+}
+
+// payload returns the payload of the description or nil
+func (desc *ActionDescription) payload() actionPayload {
+	field, ok := desc.payloadField()
+	if !ok || field.IsNil() {
+		return nil
+	}
+	return field.Interface().(actionPayload)
+}
+
+// actionHeaderFields are the fields common to all action types
+var actionHeaderFields = []string{"action", "waitForHealth", "delay"}
+
+type actionHeader struct {
+	Type          ActionType      `json:"action"`
+	WaitForHealth bool            `json:"waitForHealth"`
+	Delay         json.RawMessage `json:"delay"`
+}
+
+// parseDuration accepts either a duration string like "30s" or nanoseconds
+func parseDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return time.ParseDuration(text)
+	}
+
+	var nanos int64
+	if err := json.Unmarshal(data, &nanos); err != nil {
+		return 0, fmt.Errorf("invalid duration %s", string(data))
+	}
+
+	return time.Duration(nanos), nil
+}
+
+// Duration is a time.Duration encoded as a string like "30s". Decoding
+// also accepts nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	value, err := parseDuration(data)
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// UnmarshalJSON decodes the payload matching the action type. Unknown
+// fields and invalid payloads are rejected.
+func (desc *ActionDescription) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	var header actionHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return err
+	}
+
+	if header.Type == "" {
+		return withPath("action", fmt.Errorf("missing action type"))
+	}
+	if _, ok := actionRegistry[header.Type]; !ok {
+		return withPath("action", fmt.Errorf("unknown action type %q", header.Type))
+	}
+
+	delay, err := parseDuration(header.Delay)
+	if err != nil {
+		return withPath("delay", err)
+	}
+
+	*desc = ActionDescription{
+		Type:          header.Type,
+		WaitForHealth: header.WaitForHealth,
+		Delay:         delay,
+	}
+
+	for _, name := range actionHeaderFields {
+		delete(fields, name)
+	}
+
+	payload := desc.newPayload()
+	if payload == nil {
+		for name := range fields {
+			return withPath(name, fmt.Errorf("unknown field for action %s", desc.Type))
+		}
+		return nil
+	}
+
+	if err := decodeFields(fields, payload); err != nil {
+		return err
+	}
+
+	return payload.Validate()
+}
+
+// decodeFields decodes the fields into the struct pointed to by v, one field
+// at a time, so errors of nested values carry the name of the field. Fields
+// without matching struct field are rejected.
+func decodeFields(fields map[string]json.RawMessage, v interface{}) error {
+	value := reflect.ValueOf(v).Elem()
+	known := make(map[string]bool)
+
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		known[name] = true
+
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(value.Field(i).Addr().Interface()); err != nil {
+			return withPath(name, err)
+		}
+	}
+
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return withPath(unknown[0], fmt.Errorf("unknown field"))
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the description in the format read by UnmarshalJSON
+func (desc ActionDescription) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{})
+
+	if payload := desc.payload(); payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+	}
+
+	fields["action"] = desc.Type
+	if desc.WaitForHealth {
+		fields["waitForHealth"] = true
+	}
+	if desc.Delay != 0 {
+		fields["delay"] = desc.Delay.String()
+	}
+
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON decodes the list and reports the index of the failing action
+func (list *ActionDescriptionList) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	result := make(ActionDescriptionList, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &result[i]); err != nil {
+			return withPath(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+
+	*list = result
+	return nil
+}
+
+// UnmarshalJSON decodes the choice and reports the path of invalid actions
+func (choice *ActionChoice) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*choice = ActionChoice{Weight: 1}
+	return decodeFields(fields, choice)
+}
+
+// UnmarshalJSON decodes the list and reports the index of the failing choice
+func (list *ActionChoiceList) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	result := make(ActionChoiceList, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &result[i]); err != nil {
+			return withPath(fmt.Sprintf("[%d]", i), err)
+		}
+	}
+
+	*list = result
+	return nil
+}
+
+// UnmarshalJSON decodes the script and reports the path of invalid actions.
+// Unknown fields and scripts without actions are rejected.
+func (script *ActionScript) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	*script = ActionScript{}
+	if err := decodeFields(fields, script); err != nil {
+		return err
+	}
+
+	if len(script.Actions) == 0 {
+		return withPath("actions", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionCreateDeploymentDescription) Validate() error {
+	if d.Name == "" {
+		return withPath("name", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionDeleteDeploymentDescription) Validate() error {
+	if d.Name == "" {
+		return withPath("name", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionDeployOperatorDescription) Validate() error {
+	if d.Image == "" {
+		return withPath("image", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionDeleteOperatorDescription) Validate() error {
+	return nil
+}
+
+func (d *ActionDeletePodDescription) Validate() error {
+	if d.GracePeriod != nil && *d.GracePeriod < 0 {
+		return withPath("gracePeriod", fmt.Errorf("must not be negative"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionEvictPodDescription) Validate() error {
+	if d.Timeout < 0 {
+		return withPath("timeout", fmt.Errorf("must not be negative"))
+	}
+	if d.ForceDelete && d.Timeout == 0 {
+		return withPath("forceDelete", fmt.Errorf("requires a timeout"))
+	}
+	if d.ExpectBlocked {
+		if d.Timeout == 0 {
+			return withPath("expectBlocked", fmt.Errorf("requires a timeout"))
+		}
+		if d.ForceDelete {
+			return withPath("expectBlocked", fmt.Errorf("can not be combined with forceDelete"))
+		}
+		if !d.WaitForCompletion {
+			return withPath("expectBlocked", fmt.Errorf("requires waitForCompletion"))
+		}
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionDrainNodeDescription) Validate() error {
+	if d.GracePeriod != nil && *d.GracePeriod < 0 {
+		return withPath("gracePeriod", fmt.Errorf("must not be negative"))
+	}
+	if d.Timeout < 0 {
+		return withPath("timeout", fmt.Errorf("must not be negative"))
+	}
+	if d.MaxParallel < 0 {
+		return withPath("maxParallel", fmt.Errorf("must not be negative"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionDeletePVCDescription) Validate() error {
+	if !d.DeletePod && !d.RemoveFinalizer {
+		// The pvc-protection finalizer keeps the PVC as long as the pod uses it
+		return fmt.Errorf("requires deletePod or removeFinalizer, the PVC is never removed otherwise")
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionKillNodeDescription) Validate() error {
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionUncordonNodeDescription) Validate() error {
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionRestoreNodeDescription) Validate() error {
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionWaitDescription) Validate() error {
+	if d.Duration <= 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	return nil
+}
+
+func (m ZoneOutageMode) Validate() error {
+	switch m {
+	case ZoneOutageKill, ZoneOutageDrain:
+		return nil
+	}
+	return fmt.Errorf("unknown mode %q, expected %s or %s", m, ZoneOutageKill, ZoneOutageDrain)
+}
+
+func (d *ActionZoneOutageDescription) Validate() error {
+	if d.Zone == "" && len(d.Nodes) == 0 {
+		return fmt.Errorf("requires a zone or nodes")
+	}
+	if err := d.Mode.Validate(); err != nil {
+		return withPath("mode", err)
+	}
+	if d.Outage <= 0 {
+		return withPath("outage", fmt.Errorf("must be positive"))
+	}
+	return nil
+}
+
+func (d *ActionPartitionDescription) Validate() error {
+	if d.Target.Group == PodGroupOperator {
+		return withPath("target.group", fmt.Errorf("must be a server group"))
+	}
+	for i, group := range d.From {
+		if !group.IsValid() || group == PodGroupOperator {
+			return withPath(fmt.Sprintf("from[%d]", i), fmt.Errorf("unknown server group %q", group))
+		}
+	}
+	if d.Duration <= 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+// validateNetem checks the emulation parameters of Netem actions and faults
+func validateNetem(latency, jitter Duration, loss float64) error {
+	if latency < 0 {
+		return withPath("latency", fmt.Errorf("must not be negative"))
+	}
+	if jitter < 0 {
+		return withPath("jitter", fmt.Errorf("must not be negative"))
+	}
+	if jitter > 0 && latency == 0 {
+		return withPath("jitter", fmt.Errorf("requires a latency"))
+	}
+	if loss < 0 || loss > 100 {
+		return withPath("loss", fmt.Errorf("must be a percentage between 0 and 100"))
+	}
+	if latency == 0 && loss == 0 {
+		return fmt.Errorf("requires a latency or a loss")
+	}
+	return nil
+}
+
+func (d *ActionNetemDescription) Validate() error {
+	if d.Target.Group == PodGroupOperator {
+		return withPath("target.group", fmt.Errorf("must be a server group"))
+	}
+	if err := validateNetem(d.Latency, d.Jitter, d.Loss); err != nil {
+		return err
+	}
+	if d.Duration <= 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionFreezeDescription) Validate() error {
+	if d.Target.Group == PodGroupOperator {
+		return withPath("target.group", fmt.Errorf("must be a server group"))
+	}
+	if d.Duration <= 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (s ProcessSignal) Validate() error {
+	switch s {
+	case SignalTerm, SignalInt, SignalKill:
+		return nil
+	}
+	return fmt.Errorf("unknown signal %q, expected %s, %s or %s", s, SignalTerm, SignalInt, SignalKill)
+}
+
+func (d *ActionKillProcessDescription) Validate() error {
+	if d.Target.Group == PodGroupOperator {
+		return withPath("target.group", fmt.Errorf("must be a server group"))
+	}
+	if err := d.Signal.Validate(); err != nil {
+		return withPath("signal", err)
+	}
+	if d.HelperImage != "" && d.Signal != SignalKill {
+		return withPath("helperImage", fmt.Errorf("only used for signal %s", SignalKill))
+	}
+	if d.Timeout < 0 {
+		return withPath("timeout", fmt.Errorf("must not be negative"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+// validateFillPercent checks the percentage of DiskFull actions and faults
+func validateFillPercent(percent int) error {
+	if percent < 1 || percent > 100 {
+		return withPath("percent", fmt.Errorf("must be between 1 and 100"))
+	}
+	return nil
+}
+
+func (d *ActionDiskFullDescription) Validate() error {
+	switch d.Target.Group {
+	case PodGroupOperator, PodGroupCoordinator:
+		return withPath("target.group", fmt.Errorf("%s has no data volume", d.Target.Group))
+	}
+	if err := validateFillPercent(d.Percent); err != nil {
+		return err
+	}
+	if d.Duration <= 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	return withPath("target", d.Target.Validate())
+}
+
+func (d *ActionRepeatDescription) Validate() error {
+	if d.Count < 0 {
+		return withPath("count", fmt.Errorf("must not be negative"))
+	}
+	if d.Duration < 0 {
+		return withPath("duration", fmt.Errorf("must not be negative"))
+	}
+	if d.Count == 0 && d.Duration == 0 {
+		return fmt.Errorf("requires a count or a duration")
+	}
+	if len(d.Actions) == 0 {
+		return withPath("actions", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionParallelDescription) Validate() error {
+	if len(d.Actions) == 0 {
+		return withPath("actions", fmt.Errorf("required"))
+	}
+	return nil
+}
+
+func (d *ActionChooseDescription) Validate() error {
+	if len(d.Choices) == 0 {
+		return withPath("choices", fmt.Errorf("required"))
+	}
+
+	total := 0
+	for i, choice := range d.Choices {
+		if choice.Weight < 0 {
+			return withPath(fmt.Sprintf("choices[%d].weight", i), fmt.Errorf("must not be negative"))
+		}
+		if len(choice.Actions) == 0 {
+			return withPath(fmt.Sprintf("choices[%d].actions", i), fmt.Errorf("required"))
+		}
+		total += choice.Weight
+	}
+	if total == 0 {
+		return withPath("choices", fmt.Errorf("at least one choice needs a positive weight"))
+	}
+	return nil
+}
+
+func (d *ActionSequenceDescription) Validate() error {
+	if len(d.Actions) == 0 {
+		return withPath("actions", fmt.Errorf("required"))
+	}
+	return nil
+}
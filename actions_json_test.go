@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// expectError fails the test unless err has the expected message, or is nil
+// if no error is expected
+func expectError(t *testing.T, err error, expected string) {
+	t.Helper()
+	if expected == "" {
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("expected error %q, got nil", expected)
+	}
+	if err.Error() != expected {
+		t.Fatalf("expected error %q, got %q", expected, err.Error())
+	}
+}
+
+func TestActionDescriptionUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "valid",
+			input: `{"action":"DeletePod","target":{"group":"DBServer"},"delay":"10s"}`,
+		},
+		{
+			name:  "missing type",
+			input: `{"target":{"group":"DBServer"}}`,
+			err:   "action: missing action type",
+		},
+		{
+			name:  "unknown type",
+			input: `{"action":"DeletePods"}`,
+			err:   `action: unknown action type "DeletePods"`,
+		},
+		{
+			name:  "unknown field",
+			input: `{"action":"DeletePod","targt":{"group":"DBServer"}}`,
+			err:   "targt: unknown field",
+		},
+		{
+			name:  "unknown nested field",
+			input: `{"action":"DeletePod","target":{"grop":"DBServer"}}`,
+			err:   `target: json: unknown field "grop"`,
+		},
+		{
+			name:  "unknown group",
+			input: `{"action":"DeletePod","target":{"group":"Agents"}}`,
+			err:   `target.group: unknown pod group "Agents"`,
+		},
+		{
+			name:  "invalid delay",
+			input: `{"action":"DeletePod","target":{"group":"Agent"},"delay":"soon"}`,
+			err:   `delay: time: invalid duration "soon"`,
+		},
+		{
+			name:  "invalid payload",
+			input: `{"action":"Wait"}`,
+			err:   "duration: must be positive",
+		},
+		{
+			name:  "force delete without timeout",
+			input: `{"action":"EvictPod","target":{"group":"DBServer"},"forceDelete":true}`,
+			err:   "forceDelete: requires a timeout",
+		},
+		{
+			name:  "nested action",
+			input: `{"action":"Repeat","count":2,"actions":[{"action":"Wait","duration":"1s"},{"action":"DeletePod","target":{"group":"Agents"}}]}`,
+			err:   `actions[1].target.group: unknown pod group "Agents"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var desc ActionDescription
+			expectError(t, json.Unmarshal([]byte(test.input), &desc), test.err)
+		})
+	}
+}
+
+func TestActionDescriptionPayload(t *testing.T) {
+	var desc ActionDescription
+	input := `{"action":"DeletePod","target":{"group":"Agent","isLeader":true},"gracePeriod":5,"waitForHealth":true,"delay":"1m"}`
+	if err := json.Unmarshal([]byte(input), &desc); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if desc.Type != ActionTypeDeletePod || !desc.WaitForHealth || desc.Delay != time.Minute {
+		t.Fatalf("unexpected header %+v", desc)
+	}
+	if desc.DeletePod == nil {
+		t.Fatalf("payload not decoded")
+	}
+	if desc.DeletePod.Target.Group != PodGroupAgent || !desc.DeletePod.Target.IsLeader {
+		t.Errorf("unexpected target %+v", desc.DeletePod.Target)
+	}
+	if desc.DeletePod.GracePeriod == nil || *desc.DeletePod.GracePeriod != 5 {
+		t.Errorf("unexpected grace period %v", desc.DeletePod.GracePeriod)
+	}
+	if desc.EvictPod != nil {
+		t.Errorf("payload of another action type decoded")
+	}
+}
+
+func TestActionPayloadFields(t *testing.T) {
+	for actionType := range actionPayloadFields {
+		if _, ok := actionRegistry[actionType]; !ok {
+			t.Errorf("payload field %s does not match a registered action type", actionType)
+		}
+	}
+
+	desc := ActionDescription{Type: ActionTypeWait}
+	if desc.payload() != nil {
+		t.Errorf("payload of a new description is not nil")
+	}
+	payload := desc.newPayload()
+	if payload == nil || payload != desc.payload() || desc.Wait == nil {
+		t.Errorf("payload not allocated in its field")
+	}
+}
+
+func TestActionScriptUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "valid",
+			input: `{"seed":1,"actions":[{"action":"Wait","duration":"1s"}]}`,
+		},
+		{
+			name:  "misspelled actions",
+			input: `{"actons":[{"action":"Wait","duration":"1s"}]}`,
+			err:   "actons: unknown field",
+		},
+		{
+			name:  "no actions",
+			input: `{"seed":1}`,
+			err:   "actions: required",
+		},
+		{
+			name:  "empty actions",
+			input: `{"actions":[]}`,
+			err:   "actions: required",
+		},
+		{
+			name:  "invalid action",
+			input: `{"actions":[{"action":"Wait","duration":"1s"},{"action":"Wait"}]}`,
+			err:   "actions[1].duration: must be positive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var script ActionScript
+			expectError(t, json.Unmarshal([]byte(test.input), &script), test.err)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/cache"
+)
+
+// partitionRecoveryTimeout limits the wait for the deployment to be ready
+// after healing a partition
+const partitionRecoveryTimeout = 10 * time.Minute
+
+type actionPartition struct {
+	target   PodTarget
+	from     []PodGroup
+	duration time.Duration
+}
+
+func newActionPartition(desc ActionDescription) (Action, error) {
+	if desc.Partition == nil {
+		return nil, errMissingDescription("partition")
+	}
+
+	from := desc.Partition.From
+	if len(from) == 0 {
+		from = []PodGroup{PodGroupAgent, PodGroupDBServer, PodGroupCoordinator}
+	}
+
+	return &actionPartition{
+		target:   desc.Partition.Target,
+		from:     from,
+		duration: time.Duration(desc.Partition.Duration),
+	}, nil
+}
+
+// peers returns the names of the member pods of the From groups of the
+// deployment, without the pod itself
+func (a *actionPartition) peers(ctx context.Context, iface ActionInterface, pod Pod, deployment string) ([]string, error) {
+	var peers []string
+	for _, group := range a.from {
+		candidates, err := iface.Pods().TargetCandidates(ctx, PodTarget{
+			Group:      group,
+			Namespace:  pod.Namespace(),
+			Deployment: deployment,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range candidates {
+			_, name, err := cache.SplitMetaNamespaceKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if name != pod.Name() {
+				peers = append(peers, name)
+			}
+		}
+	}
+
+	if len(peers) == 0 {
+		return nil, errors.Errorf("no members of %v to partition %s from", a.from, pod.Name())
+	}
+	return peers, nil
+}
+
+// Run isolates the pod for the duration, then heals the partition and waits
+// for the deployment to be ready. The partition is removed even if the
+// context is done, so no policy is left behind.
+func (a *actionPartition) Run(ctx context.Context, iface ActionInterface) error {
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := pod.Deployment()
+	if err != nil {
+		return err
+	}
+
+	peers, err := a.peers(ctx, iface, pod, deployment)
+	if err != nil {
+		return err
+	}
+
+	reconnect := iface.Cleanups().Register("partition of pod "+pod.Name(), func() error {
+		return pod.Reconnect(context.Background())
+	})
+
+	var errs []error
+	if err := pod.Isolate(ctx, peers); err != nil {
+		errs = append(errs, err)
+	} else {
+		log.Printf("Pod %s is partitioned, reconnecting in %s", pod.Name(), a.duration)
+		if err := iface.Sleep(ctx, a.duration); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := reconnect(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		log.Printf("Waiting for deployment %s to recover from the partition of %s", deployment, pod.Name())
+		recoveryCtx, cancel := context.WithTimeout(ctx, partitionRecoveryTimeout)
+		defer cancel()
+		if err := iface.Deployment().Deployment(pod.Namespace() + "/" + deployment).WaitForReady(recoveryCtx); err != nil {
+			errs = append(errs, errors.Wrapf(err, "deployment %s did not recover from the partition", deployment))
+		}
+	}
+
+	return k8serrors.NewAggregate(errs)
+}
+
+type actionNetem struct {
+	target   PodTarget
+	options  NetemOptions
+	duration time.Duration
+}
+
+func newActionNetem(desc ActionDescription) (Action, error) {
+	if desc.Netem == nil {
+		return nil, errMissingDescription("netem")
+	}
+
+	duration := time.Duration(desc.Netem.Duration)
+	return &actionNetem{
+		target: desc.Netem.Target,
+		options: NetemOptions{
+			Latency:     time.Duration(desc.Netem.Latency),
+			Jitter:      time.Duration(desc.Netem.Jitter),
+			Loss:        desc.Netem.Loss,
+			HelperImage: desc.Netem.HelperImage,
+			Deadline:    duration,
+		},
+		duration: duration,
+	}, nil
+}
+
+// Run degrades the network of the pod for the duration. The emulation is
+// removed even if the context is done.
+func (a *actionNetem) Run(ctx context.Context, iface ActionInterface) error {
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	if err := pod.Netem(ctx, a.options); err != nil {
+		return err
+	}
+
+	remove := iface.Cleanups().Register("network emulation of pod "+pod.Name(), func() error {
+		return pod.ClearNetem(context.Background(), a.options)
+	})
+
+	var errs []error
+	log.Printf("Pod %s has %s, removing in %s", pod.Name(), a.options, a.duration)
+	if err := iface.Sleep(ctx, a.duration); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := remove(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return k8serrors.NewAggregate(errs)
+}
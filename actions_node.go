@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+type actionDrainNode struct {
+	target   NodeTarget
+	options  DrainOptions
+	uncordon bool
+}
+
+func newActionDrainNode(desc ActionDescription) (Action, error) {
+	if desc.DrainNode == nil {
+		return nil, errMissingDescription("drainNode")
+	}
+
+	return &actionDrainNode{
+		target: desc.DrainNode.Target,
+		options: DrainOptions{
+			DeleteLocalData: desc.DrainNode.DeleteLocalData,
+			GracePeriod:     desc.DrainNode.GracePeriod,
+			Timeout:         time.Duration(desc.DrainNode.Timeout),
+			MaxParallel:     desc.DrainNode.MaxParallel,
+		},
+		uncordon: desc.DrainNode.Uncordon,
+	}, nil
+}
+
+func (a *actionDrainNode) Run(ctx context.Context, iface ActionInterface) error {
+
+	node, err := a.target.Resolve(ctx, iface)
+	if err != nil {
+		return err
+	}
+
+	result, err := node.Drain(ctx, a.options)
+	if result != nil {
+		log.Println(result.Summary())
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.uncordon {
+		return node.Uncordon()
+	}
+
+	return nil
+}
+
+type actionKillNode struct {
+	target NodeTarget
+}
+
+func newActionKillNode(desc ActionDescription) (Action, error) {
+	if desc.KillNode == nil {
+		return nil, errMissingDescription("killNode")
+	}
+
+	return &actionKillNode{
+		target: desc.KillNode.Target,
+	}, nil
+}
+
+func (a *actionKillNode) Run(ctx context.Context, iface ActionInterface) error {
+
+	node, err := a.target.Resolve(ctx, iface)
+	if err != nil {
+		return err
+	}
+
+	return node.Kill(ctx)
+}
+
+type actionUncordonNode struct {
+	target NodeTarget
+}
+
+func newActionUncordonNode(desc ActionDescription) (Action, error) {
+	if desc.UncordonNode == nil {
+		return nil, errMissingDescription("uncordonNode")
+	}
+
+	return &actionUncordonNode{
+		target: desc.UncordonNode.Target,
+	}, nil
+}
+
+func (a *actionUncordonNode) Run(ctx context.Context, iface ActionInterface) error {
+
+	node, err := a.target.Resolve(ctx, iface)
+	if err != nil {
+		return err
+	}
+
+	return node.Uncordon()
+}
+
+type actionRestoreNode struct {
+	target NodeTarget
+}
+
+func newActionRestoreNode(desc ActionDescription) (Action, error) {
+	if desc.RestoreNode == nil {
+		return nil, errMissingDescription("restoreNode")
+	}
+
+	return &actionRestoreNode{
+		target: desc.RestoreNode.Target,
+	}, nil
+}
+
+func (a *actionRestoreNode) Run(ctx context.Context, iface ActionInterface) error {
+
+	node, err := a.target.Resolve(ctx, iface)
+	if a.target.Name != "" && apierrors.IsNotFound(errors.Cause(err)) {
+		// Killing the node may have deleted it, the terminator restores it by name
+		node, err = iface.Nodes().Node(a.target.Name), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return node.Restore(ctx)
+}
+
+type actionZoneOutage struct {
+	zone      string
+	zoneLabel string
+	nodes     []string
+	mode      ZoneOutageMode
+	outage    time.Duration
+}
+
+func newActionZoneOutage(desc ActionDescription) (Action, error) {
+	if desc.ZoneOutage == nil {
+		return nil, errMissingDescription("zoneOutage")
+	}
+
+	zoneLabel := desc.ZoneOutage.ZoneLabel
+	if zoneLabel == "" {
+		zoneLabel = defaultZoneLabel
+	}
+
+	return &actionZoneOutage{
+		zone:      desc.ZoneOutage.Zone,
+		zoneLabel: zoneLabel,
+		nodes:     desc.ZoneOutage.Nodes,
+		mode:      desc.ZoneOutage.Mode,
+		outage:    time.Duration(desc.ZoneOutage.Outage),
+	}, nil
+}
+
+// resolve returns the nodes of the zone, unless nodes are given explicitly
+func (a *actionZoneOutage) resolve(iface ActionInterface) ([]Node, error) {
+	names := a.nodes
+	if len(names) == 0 {
+		zones, err := iface.Nodes().Zones(a.zoneLabel)
+		if err != nil {
+			return nil, err
+		}
+		names = zones[a.zone]
+		if len(names) == 0 {
+			return nil, errors.Errorf("no usable nodes in zone %s", a.zone)
+		}
+	}
+
+	var nodes []Node
+	for _, name := range names {
+		if err := iface.Nodes().Allowed(name); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, iface.Nodes().Node(name))
+	}
+	return nodes, nil
+}
+
+// Run cordons all nodes first, so pods can not move within the zone, then
+// kills or drains them concurrently. The nodes are restored after the
+// outage, even if taking them down failed or the context is done.
+func (a *actionZoneOutage) Run(ctx context.Context, iface ActionInterface) error {
+	nodes, err := a.resolve(iface)
+	if err != nil {
+		return err
+	}
+
+	restores := make([]cleanupFunc, len(nodes))
+	for i, node := range nodes {
+		node := node
+		restores[i] = iface.Cleanups().Register("zone outage of node "+node.Name(), func() error {
+			return a.restore(context.Background(), node)
+		})
+	}
+
+	log.Printf("Zone outage of %s with %d nodes (%s)", a.zone, len(nodes), a.mode)
+	var errs []error
+	for _, node := range nodes {
+		if err := node.Cordon(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		completion := make(chan error, len(nodes))
+		for _, node := range nodes {
+			go func(node Node) {
+				completion <- a.takeDown(ctx, node)
+			}(node)
+		}
+		for range nodes {
+			if err := waitForCompletion(ctx, completion); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		log.Printf("Zone %s is down, restoring in %s", a.zone, a.outage)
+		if err := iface.Sleep(ctx, a.outage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, restore := range restores {
+		if err := restore(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return k8serrors.NewAggregate(errs)
+}
+
+func (a *actionZoneOutage) takeDown(ctx context.Context, node Node) error {
+	if a.mode == ZoneOutageKill {
+		return node.Kill(ctx)
+	}
+
+	result, err := node.Drain(ctx, DrainOptions{DeleteLocalData: true})
+	if result != nil {
+		log.Println(result.Summary())
+	}
+	return err
+}
+
+func (a *actionZoneOutage) restore(ctx context.Context, node Node) error {
+	if a.mode == ZoneOutageKill {
+		return node.Restore(ctx)
+	}
+	return node.Uncordon()
+}
@@ -0,0 +1,38 @@
+package main
+
+import "context"
+
+type actionDeployOperator struct {
+	image string
+}
+
+func newActionDeployOperator(desc ActionDescription) (Action, error) {
+	if desc.DeployOperator == nil {
+		return nil, errMissingDescription("deployOperator")
+	}
+
+	return &actionDeployOperator{
+		image: desc.DeployOperator.Image,
+	}, nil
+}
+
+func (a *actionDeployOperator) Run(ctx context.Context, iface ActionInterface) error {
+	return iface.Operator().Deploy(ctx, a.image)
+}
+
+type actionDeleteOperator struct {
+	deleteCRD bool
+}
+
+func newActionDeleteOperator(desc ActionDescription) (Action, error) {
+	action := &actionDeleteOperator{}
+	if desc.DeleteOperator != nil {
+		action.deleteCRD = desc.DeleteOperator.DeleteCRD
+	}
+
+	return action, nil
+}
+
+func (a *actionDeleteOperator) Run(ctx context.Context, iface ActionInterface) error {
+	return iface.Operator().Delete(ctx, a.deleteCRD)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type actionDeletePod struct {
+	target            PodTarget
+	waitForCompletion bool
+	gracePeriod       *int64
+}
+
+func newActionDeletePod(desc ActionDescription) (Action, error) {
+	if desc.DeletePod == nil {
+		return nil, errMissingDescription("deletePod")
+	}
+
+	return &actionDeletePod{
+		target:            desc.DeletePod.Target,
+		waitForCompletion: desc.DeletePod.WaitForCompletion,
+		gracePeriod:       desc.DeletePod.GracePeriod,
+	}, nil
+}
+
+// targetPod resolves the given target or fails if no pod matches
+func targetPod(ctx context.Context, iface ActionInterface, target PodTarget) (Pod, error) {
+	pod, err := iface.Pods().Target(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if pod == nil {
+		return nil, fmt.Errorf("no pod matches target %+v", target)
+	}
+
+	return pod, nil
+}
+
+func (a *actionDeletePod) Run(ctx context.Context, iface ActionInterface) error {
+
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	options := metav1.DeleteOptions{GracePeriodSeconds: a.gracePeriod}
+	channel := newCompletionChannel()
+	if err := pod.Delete(ctx, channel, &options); err != nil {
+		return err
+	}
+
+	if a.waitForCompletion {
+		return waitForCompletion(ctx, channel)
+	}
+
+	logCompletion("Deletion of pod "+pod.Name(), channel)
+	return nil
+}
+
+type actionEvictPod struct {
+	target            PodTarget
+	waitForCompletion bool
+	eviction          EvictionOptions
+	expectBlocked     bool
+}
+
+func newActionEvictPod(desc ActionDescription) (Action, error) {
+	if desc.EvictPod == nil {
+		return nil, errMissingDescription("evictPod")
+	}
+
+	timeout := time.Duration(desc.EvictPod.Timeout)
+	if timeout == 0 {
+		timeout = defaultEvictionTimeout
+	}
+
+	return &actionEvictPod{
+		target:            desc.EvictPod.Target,
+		waitForCompletion: desc.EvictPod.WaitForCompletion,
+		eviction: EvictionOptions{
+			Timeout:     timeout,
+			ForceDelete: desc.EvictPod.ForceDelete,
+		},
+		expectBlocked: desc.EvictPod.ExpectBlocked,
+	}, nil
+}
+
+func (a *actionEvictPod) Run(ctx context.Context, iface ActionInterface) error {
+
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	options := metav1.DeleteOptions{}
+	channel := newCompletionChannel()
+	if err := pod.Evict(ctx, channel, &options, a.eviction); err != nil {
+		return err
+	}
+
+	if a.waitForCompletion {
+		return a.checkResult(pod, waitForCompletion(ctx, channel))
+	}
+
+	logCompletion("Eviction of pod "+pod.Name(), channel)
+	return nil
+}
+
+// checkResult treats a blocked eviction as success if it was expected
+func (a *actionEvictPod) checkResult(pod Pod, err error) error {
+	if !a.expectBlocked {
+		return err
+	}
+
+	if blocked, ok := errors.Cause(err).(*EvictionBlockedError); ok {
+		log.Printf("Eviction blocked as expected: %s", blocked.Error())
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("expected eviction of %s to be blocked", pod.Name())
+}
+
+// memberReplaceTimeout limits the wait for the operator to replace a member
+// after its PVC was deleted
+const memberReplaceTimeout = 15 * time.Minute
+
+type actionDeletePVC struct {
+	target          PodTarget
+	deletePod       bool
+	removeFinalizer bool
+	waitForRecovery bool
+}
+
+func newActionDeletePVC(desc ActionDescription) (Action, error) {
+	if desc.DeletePVC == nil {
+		return nil, errMissingDescription("deletePVC")
+	}
+
+	return &actionDeletePVC{
+		target:          desc.DeletePVC.Target,
+		deletePod:       desc.DeletePVC.DeletePod,
+		removeFinalizer: desc.DeletePVC.RemoveFinalizer,
+		waitForRecovery: desc.DeletePVC.WaitForRecovery,
+	}, nil
+}
+
+func (a *actionDeletePVC) Run(ctx context.Context, iface ActionInterface) error {
+
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	// Obtain the deployment and UID before the pod disappears
+	deployment := a.target.Deployment
+	var uid types.UID
+	if a.waitForRecovery {
+		if deployment == "" {
+			if deployment, err = pod.Deployment(); err != nil {
+				return err
+			}
+		}
+		if uid, err = pod.UID(); err != nil {
+			return err
+		}
+	}
+
+	options := metav1.DeleteOptions{}
+	pvcCompletion := make(chan error, 1)
+	if err := pod.DeletePersistentVolumeClaims(ctx, pvcCompletion, a.removeFinalizer, &options); err != nil {
+		return err
+	}
+
+	if a.deletePod {
+		podCompletion := make(chan error, 1)
+		if err := pod.Delete(ctx, podCompletion, &options); err != nil {
+			return err
+		}
+		if err := waitForCompletion(ctx, podCompletion); err != nil {
+			return err
+		}
+	}
+
+	if err := waitForCompletion(ctx, pvcCompletion); err != nil {
+		return err
+	}
+
+	if a.waitForRecovery {
+		// The member is ready until it is replaced, wait for the new pod first
+		log.Printf("Waiting for deployment %s to replace member %s", deployment, pod.Name())
+		replaceCtx, cancel := context.WithTimeout(ctx, memberReplaceTimeout)
+		defer cancel()
+		if err := pod.WaitForReplacement(replaceCtx, uid); err != nil {
+			return err
+		}
+		return iface.Deployment().Deployment(pod.Namespace() + "/" + deployment).WaitForReady(ctx)
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// freezeRecoveryTimeout limits the wait for the deployment to be ready after
+// thawing a pod
+const freezeRecoveryTimeout = 10 * time.Minute
+
+type actionFreeze struct {
+	target      PodTarget
+	helperImage string
+	duration    time.Duration
+}
+
+func newActionFreeze(desc ActionDescription) (Action, error) {
+	if desc.Freeze == nil {
+		return nil, errMissingDescription("freeze")
+	}
+
+	return &actionFreeze{
+		target:      desc.Freeze.Target,
+		helperImage: desc.Freeze.HelperImage,
+		duration:    time.Duration(desc.Freeze.Duration),
+	}, nil
+}
+
+// Run freezes the pod for the duration, then thaws it and waits for the
+// deployment to be ready. The processes are continued even if the context is
+// done.
+func (a *actionFreeze) Run(ctx context.Context, iface ActionInterface) error {
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	deployment, err := pod.Deployment()
+	if err != nil {
+		return err
+	}
+
+	if err := pod.Freeze(ctx, a.helperImage, a.duration); err != nil {
+		return err
+	}
+
+	thaw := iface.Cleanups().Register("freeze of pod "+pod.Name(), func() error {
+		return pod.Thaw(context.Background())
+	})
+
+	var errs []error
+	log.Printf("Pod %s is frozen, thawing in %s", pod.Name(), a.duration)
+	if err := iface.Sleep(ctx, a.duration); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := thaw(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		log.Printf("Waiting for deployment %s to recover from the freeze of %s", deployment, pod.Name())
+		recoveryCtx, cancel := context.WithTimeout(ctx, freezeRecoveryTimeout)
+		defer cancel()
+		if err := iface.Deployment().Deployment(pod.Namespace() + "/" + deployment).WaitForReady(recoveryCtx); err != nil {
+			errs = append(errs, errors.Wrapf(err, "deployment %s did not recover from the freeze", deployment))
+		}
+	}
+
+	return k8serrors.NewAggregate(errs)
+}
+
+// defaultRestartTimeout limits the wait for a killed container to run again
+const defaultRestartTimeout = 5 * time.Minute
+
+type actionKillProcess struct {
+	target      PodTarget
+	signal      ProcessSignal
+	helperImage string
+	timeout     time.Duration
+}
+
+func newActionKillProcess(desc ActionDescription) (Action, error) {
+	if desc.KillProcess == nil {
+		return nil, errMissingDescription("killProcess")
+	}
+
+	timeout := time.Duration(desc.KillProcess.Timeout)
+	if timeout == 0 {
+		timeout = defaultRestartTimeout
+	}
+
+	return &actionKillProcess{
+		target:      desc.KillProcess.Target,
+		signal:      desc.KillProcess.Signal,
+		helperImage: desc.KillProcess.HelperImage,
+		timeout:     timeout,
+	}, nil
+}
+
+// Run kills the process and waits until the restart count of the container
+// increased and it is running again
+func (a *actionKillProcess) Run(ctx context.Context, iface ActionInterface) error {
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	before, _, err := pod.RestartCount()
+	if err != nil {
+		return err
+	}
+
+	if err := pod.Signal(ctx, a.signal, a.helperImage); err != nil {
+		return err
+	}
+
+	log.Printf("Waiting for the container of pod %s to restart, restart count %d", pod.Name(), before)
+	deadline := iface.Now().Add(a.timeout)
+	for {
+		after, running, err := pod.RestartCount()
+		if err != nil {
+			return err
+		}
+		if after > before && running {
+			log.Printf("Container of pod %s restarted, restart count %d -> %d", pod.Name(), before, after)
+			return nil
+		}
+		if iface.Now().After(deadline) {
+			return errors.Errorf("container of pod %s is not running again after %s, restart count %d -> %d",
+				pod.Name(), a.timeout, before, after)
+		}
+
+		if err := iface.Sleep(ctx, 2*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+type actionDiskFull struct {
+	target   PodTarget
+	percent  int
+	duration time.Duration
+}
+
+func newActionDiskFull(desc ActionDescription) (Action, error) {
+	if desc.DiskFull == nil {
+		return nil, errMissingDescription("diskFull")
+	}
+
+	return &actionDiskFull{
+		target:   desc.DiskFull.Target,
+		percent:  desc.DiskFull.Percent,
+		duration: time.Duration(desc.DiskFull.Duration),
+	}, nil
+}
+
+// Run fills the data volume for the duration. The cleanup is registered
+// before filling, so a partially filled volume is freed as well.
+func (a *actionDiskFull) Run(ctx context.Context, iface ActionInterface) error {
+	pod, err := targetPod(ctx, iface, a.target)
+	if err != nil {
+		return err
+	}
+
+	free := iface.Cleanups().Register("filled data volume of pod "+pod.Name(), func() error {
+		return pod.FreeVolume(context.Background())
+	})
+
+	var errs []error
+	if err := pod.FillVolume(ctx, a.percent); err != nil {
+		errs = append(errs, err)
+	} else {
+		log.Printf("Data volume of pod %s is full, freeing in %s", pod.Name(), a.duration)
+		if err := iface.Sleep(ctx, a.duration); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := free(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return k8serrors.NewAggregate(errs)
+}
@@ -1,6 +1,16 @@
 package main
 
-import k8s "k8s.io/client-go/kubernetes"
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/arangodb/go-driver/http"
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
 
 type AgencyLogger interface {
 	Stop()
@@ -12,3 +22,90 @@ func NewAgencyLogger(client k8s.Interface, namespace, deployment string) (Agency
 	// Watch all Pods that belong to the deployment and have role=agent
 	return nil, nil
 }
+
+// agencyConfig is the part of the /_api/agency/config response we are interested in
+type agencyConfig struct {
+	LeaderID string `json:"leaderId"`
+}
+
+// getAgencyConfig reads the agency configuration from the agent running in the given pod.
+// The agent is contacted directly using its pod IP.
+func getAgencyConfig(ctx context.Context, client k8s.Interface, namespace, podName string, hasTLS bool, token string) (*agencyConfig, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get agent pod")
+	}
+
+	if pod.Status.PodIP == "" {
+		return nil, fmt.Errorf("agent pod %s has no IP", podName)
+	}
+
+	var config http.ConnectionConfig
+	if hasTLS {
+		config.Endpoints = []string{"https://" + pod.Status.PodIP + ":8529"}
+		config.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	} else {
+		config.Endpoints = []string{"http://" + pod.Status.PodIP + ":8529"}
+	}
+	config.DontFollowRedirect = true
+
+	conn, err := http.NewConnection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := conn.NewRequest("GET", "_api/agency/config")
+	if err != nil {
+		return nil, err
+	}
+	req.SetHeader("Authorization", "bearer "+token)
+
+	resp, err := conn.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := resp.CheckStatus(200); err != nil {
+		return nil, err
+	}
+
+	var result agencyConfig
+	if err := resp.ParseBody("", &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// getAgencyLeader returns the member ID of the current agency leader of the
+// deployment. All agents are asked in turn until one knows the leader.
+func getAgencyLeader(ctx context.Context, client k8s.Interface, namespace string, deployment *arangoapi.ArangoDeployment) (string, error) {
+	token, err := generateJWTForDeployment(client, namespace, deployment)
+	if err != nil {
+		return "", err
+	}
+
+	hasTLS := deployment.Spec.TLS.GetCASecretName() != "None"
+
+	var lastErr error
+	for _, agent := range deployment.Status.Members.Agents {
+		if agent.PodName == "" {
+			continue
+		}
+
+		config, err := getAgencyConfig(ctx, client, namespace, agent.PodName, hasTLS, token)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if config.LeaderID != "" {
+			return config.LeaderID, nil
+		}
+	}
+
+	if lastErr != nil {
+		return "", errors.Wrap(lastErr, "no agent reported a leader")
+	}
+	return "", errors.New("no agent reported a leader")
+}
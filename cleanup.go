@@ -0,0 +1,68 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// cleanupFunc reverts a fault
+type cleanupFunc func() error
+
+// CleanupRegistry keeps the cleanups of running faults, so that they can
+// be run if the agent is interrupted or fails before the faults end
+type CleanupRegistry struct {
+	mutex    sync.Mutex
+	next     int
+	cleanups map[int]registeredCleanup
+}
+
+type registeredCleanup struct {
+	name    string
+	cleanup cleanupFunc
+}
+
+// NewCleanupRegistry creates an empty registry
+func NewCleanupRegistry() *CleanupRegistry {
+	return &CleanupRegistry{
+		cleanups: make(map[int]registeredCleanup),
+	}
+}
+
+// Register adds a cleanup and returns a function running and removing it.
+// The cleanup runs at most once, either through the returned function or
+// through RunAll.
+func (r *CleanupRegistry) Register(name string, cleanup cleanupFunc) cleanupFunc {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := r.next
+	r.next++
+	r.cleanups[id] = registeredCleanup{name: name, cleanup: cleanup}
+
+	return func() error {
+		r.mutex.Lock()
+		c, ok := r.cleanups[id]
+		delete(r.cleanups, id)
+		r.mutex.Unlock()
+
+		if !ok {
+			return nil
+		}
+		return c.cleanup()
+	}
+}
+
+// RunAll runs all pending cleanups and logs their failures
+func (r *CleanupRegistry) RunAll() {
+	r.mutex.Lock()
+	cleanups := r.cleanups
+	r.cleanups = make(map[int]registeredCleanup)
+	r.mutex.Unlock()
+
+	for _, c := range cleanups {
+		log.Printf("Cleaning up %s", c.name)
+		if err := c.cleanup(); err != nil {
+			log.Printf("Cleanup of %s failed: %s", c.name, err.Error())
+		}
+	}
+}
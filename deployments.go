@@ -1,13 +1,182 @@
 package main
 
-import driver "github.com/arangodb/go-driver"
+import (
+	"context"
+	"fmt"
+	"log"
+
+	driver "github.com/arangodb/go-driver"
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
 
 type Deployment interface {
-	Delete() error
-	Database() driver.Client
+	// Delete deletes the deployment and waits until all its pods and PVCs are gone
+	Delete(ctx context.Context) error
+	// Database returns an authenticated client for the deployment
+	Database(ctx context.Context) (driver.Client, error)
+	// WaitForReady waits until all members are ready and all shards are in sync
+	WaitForReady(ctx context.Context) error
 }
 
 type DeploymentManager interface {
+	// Deployment returns the deployment with the given name. The name may
+	// be qualified as namespace/name, otherwise the namespace of the
+	// manager is used.
 	Deployment(name string) Deployment
-	New() (Deployment, error)
+	// New creates a new deployment and waits until it is ready
+	New(ctx context.Context, name string, spec arangoapi.DeploymentSpec) (Deployment, error)
+	// Allowed returns an error if the target selector excludes the deployment
+	Allowed(name string) error
+}
+
+// deploymentManager manages the ArangoDeployments of a namespace
+type deploymentManager struct {
+	client    k8s.Interface
+	arango    arangoclient.DatabaseV1alphaInterface
+	namespace string
+	health    *healthChecker
+	selector  TargetSelector
+}
+
+// NewDeploymentManager creates a deployment manager for the given namespace.
+// Only deployments allowed by the selector may be created or deleted.
+func NewDeploymentManager(client k8s.Interface, arango arangoclient.DatabaseV1alphaInterface, namespace string, health *healthChecker, selector TargetSelector) (DeploymentManager, error) {
+	return &deploymentManager{
+		client:    client,
+		arango:    arango,
+		namespace: namespace,
+		health:    health,
+		selector:  selector,
+	}, nil
+}
+
+type deployment struct {
+	manager   *deploymentManager
+	namespace string
+	name      string
+}
+
+// Deployment returns the control interface for the given deployment
+func (dm *deploymentManager) Deployment(name string) Deployment {
+	namespace, name, err := cache.SplitMetaNamespaceKey(name)
+	if err != nil || namespace == "" {
+		namespace = dm.namespace
+	}
+
+	return &deployment{
+		manager:   dm,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (dm *deploymentManager) Allowed(name string) error {
+	_, name, err := cache.SplitMetaNamespaceKey(name)
+	if err != nil {
+		return err
+	}
+	if !dm.selector.AllowsDeployment(name) {
+		return errors.Errorf("deployment %s is not allowed by the target selector", name)
+	}
+	return nil
+}
+
+// New creates an ArangoDeployment from the spec and waits until it is
+// running and all its members are ready
+func (dm *deploymentManager) New(ctx context.Context, name string, spec arangoapi.DeploymentSpec) (Deployment, error) {
+	obj := &arangoapi.ArangoDeployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: dm.namespace,
+		},
+		Spec: spec,
+	}
+
+	log.Printf("Creating deployment %s/%s", dm.namespace, name)
+	if _, err := dm.arango.ArangoDeployments(dm.namespace).Create(obj); err != nil {
+		return nil, errors.Wrap(err, "failed to create deployment")
+	}
+
+	if err := retry(ctx, func() error {
+		current, err := dm.arango.ArangoDeployments(dm.namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		return checkMembersReady(dm.client, dm.namespace, current)
+	}); err != nil {
+		return nil, errors.Wrap(err, "deployment did not become ready")
+	}
+
+	log.Printf("Deployment %s/%s is ready", dm.namespace, name)
+	return dm.Deployment(name), nil
+}
+
+// Delete removes the ArangoDeployment and waits until the deployment
+// resource, its pods and its PVCs are gone
+func (d *deployment) Delete(ctx context.Context) error {
+	dm := d.manager
+
+	log.Printf("Deleting deployment %s/%s", d.namespace, d.name)
+	if err := dm.arango.ArangoDeployments(d.namespace).Delete(d.name, &metav1.DeleteOptions{}); err != nil {
+		return errors.Wrap(err, "failed to delete deployment")
+	}
+
+	selector := labels.SelectorFromSet(k8sutil.LabelsForDeployment(d.name, "")).String()
+
+	if err := retry(ctx, func() error {
+		if _, err := dm.arango.ArangoDeployments(d.namespace).Get(d.name, metav1.GetOptions{}); err == nil {
+			return fmt.Errorf("deployment %s still exists", d.name)
+		} else if !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		pods, err := dm.client.CoreV1().Pods(d.namespace).List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) > 0 {
+			return fmt.Errorf("deployment %s still has %d pods", d.name, len(pods.Items))
+		}
+
+		pvcs, err := dm.client.CoreV1().PersistentVolumeClaims(d.namespace).List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return err
+		}
+		if len(pvcs.Items) > 0 {
+			return fmt.Errorf("deployment %s still has %d pvcs", d.name, len(pvcs.Items))
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "failed to wait for deployment deletion")
+	}
+
+	log.Printf("Deployment %s/%s deleted", d.namespace, d.name)
+	return nil
+}
+
+// Database returns a client connected through the external access service
+func (d *deployment) Database(ctx context.Context) (driver.Client, error) {
+	client, err := d.manager.health.newDatabaseClient(ctx, d.namespace, d.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		return nil, fmt.Errorf("no external access to deployment %s yet", d.name)
+	}
+
+	return client, nil
+}
+
+func (d *deployment) WaitForReady(ctx context.Context) error {
+	return d.manager.health.WaitForDeploymentReady(ctx, d.namespace, d.name)
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// dataVolumePath is the mount path of the data volume of the members
+	dataVolumePath = "/data"
+	// diskFillFile takes the space of a filled volume
+	diskFillFile = dataVolumePath + "/.chaos-fill"
+	// diskFreeTimeout limits the retries of freeing a volume, e.g. while
+	// the container restarts
+	diskFreeTimeout = 5 * time.Minute
+)
+
+// diskFillScript fills the volume up to the percentage of its size. The
+// fill file is allocated at once if possible, otherwise written until the
+// target size or the end of the space is reached.
+const diskFillScript = `
+set -- $(df -Pk ` + dataVolumePath + ` | tail -n 1)
+fill=$(( $2 * %d / 100 - $3 ))
+if [ "$fill" -gt 0 ]; then
+  fallocate -l $(( fill * 1024 )) ` + diskFillFile + ` 2>/dev/null ||
+    dd if=/dev/zero of=` + diskFillFile + ` bs=1048576 count=$(( fill / 1024 )) 2>/dev/null || true
+fi
+df -Pk ` + dataVolumePath + ` | tail -n 1
+`
+
+// fillVolume fills the data volume of the pod to the given percentage
+func fillVolume(config *rest.Config, client k8s.Interface, namespace, name string, percent int) error {
+	script := fmt.Sprintf(diskFillScript, percent)
+	usage, err := execInPod(config, client, namespace, name, k8sutil.ServerContainerName, []string{"sh", "-c", script})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Filled data volume of pod %s/%s to %d%%: %s", namespace, name, percent, strings.TrimSpace(usage))
+	return nil
+}
+
+// freeVolume removes the fill file of the data volume of the pod. Retries
+// until the container runs, e.g. after arangod failed on the full volume.
+func freeVolume(ctx context.Context, config *rest.Config, client k8s.Interface, namespace, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, diskFreeTimeout)
+	defer cancel()
+
+	err := retry(ctx, func() error {
+		_, err := execInPod(config, client, namespace, name, k8sutil.ServerContainerName, []string{"rm", "-f", diskFillFile})
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to free data volume of pod %s", name)
+	}
+
+	log.Printf("Freed data volume of pod %s/%s", namespace, name)
+	return nil
+}
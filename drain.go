@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8serrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DrainOptions mirror the options of kubectl drain
+type DrainOptions struct {
+	// DeleteLocalData allows evicting pods using emptyDir volumes
+	DeleteLocalData bool
+	// GracePeriod overrides the termination grace period of the pods
+	GracePeriod *int64
+	// Timeout limits the duration of the whole drain, zero waits forever
+	Timeout time.Duration
+	// MaxParallel limits the number of concurrent evictions, zero is unlimited
+	MaxParallel int
+}
+
+// DrainPodResult is the outcome of draining a single pod
+type DrainPodResult struct {
+	Namespace string
+	Name      string
+	// Skipped is the reason the pod was not evicted, if any
+	Skipped  string
+	Duration time.Duration
+	// Eviction records how long PodDisruptionBudgets blocked the eviction
+	Eviction EvictionResult
+	Err      error
+}
+
+// DrainResult summarizes the drain of a node
+type DrainResult struct {
+	Node string
+	Pods []DrainPodResult
+}
+
+// Summary returns a human readable per pod summary of the drain
+func (r *DrainResult) Summary() string {
+	var evicted, skipped, failed, blocked, forced int
+	var lines []string
+
+	for _, p := range r.Pods {
+		if p.Eviction.Blocked > 0 {
+			blocked++
+		}
+		if p.Eviction.Forced {
+			forced++
+		}
+
+		switch {
+		case p.Skipped != "":
+			skipped++
+			lines = append(lines, fmt.Sprintf("  %s/%s skipped: %s", p.Namespace, p.Name, p.Skipped))
+		case p.Err != nil:
+			failed++
+			lines = append(lines, fmt.Sprintf("  %s/%s failed after %s: %s", p.Namespace, p.Name, p.Duration.Round(time.Second), p.Err.Error()))
+		case p.Eviction.Blocked > 0:
+			evicted++
+			lines = append(lines, fmt.Sprintf("  %s/%s evicted in %s, %s", p.Namespace, p.Name, p.Duration.Round(time.Second), p.Eviction.String()))
+		default:
+			evicted++
+			lines = append(lines, fmt.Sprintf("  %s/%s evicted in %s", p.Namespace, p.Name, p.Duration.Round(time.Second)))
+		}
+	}
+
+	header := fmt.Sprintf("Drain of node %s: %d evicted, %d skipped, %d failed, %d blocked by PodDisruptionBudgets, %d force deleted",
+		r.Node, evicted, skipped, failed, blocked, forced)
+	return strings.Join(append([]string{header}, lines...), "\n")
+}
+
+// drainSkipReason returns why a pod is not evicted during a drain, if at all
+func drainSkipReason(pod *v1.Pod) string {
+	// Ignore daemonsets
+	controller := metav1.GetControllerOf(pod)
+	if controller != nil && controller.Kind == "DaemonSet" {
+		return "DaemonSet"
+	}
+	// Ignore mirror pods
+	if _, found := pod.ObjectMeta.Annotations[v1.MirrorPodAnnotationKey]; found {
+		return "mirror pod"
+	}
+	return ""
+}
+
+func hasLocalData(pod *v1.Pod) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.EmptyDir != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// DrainNode drains the given node by cordon it and evicting all pods
+// except DaemonSet and mirror pods. Like kubectl, the drain is refused
+// before cordoning if pods with local data exist and DeleteLocalData is not
+// set.
+func (nm *nodeManager) DrainNode(ctx context.Context, name string, options DrainOptions) (*DrainResult, error) {
+
+	pods, err := nm.GetNodePods(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to drain node")
+	}
+
+	result := &DrainResult{Node: name}
+	var evict []v1.Pod
+	var localData []string
+
+	for _, pod := range pods {
+		if reason := drainSkipReason(&pod); reason != "" {
+			result.Pods = append(result.Pods, DrainPodResult{Namespace: pod.GetNamespace(), Name: pod.GetName(), Skipped: reason})
+			continue
+		}
+		if hasLocalData(&pod) && !options.DeleteLocalData {
+			localData = append(localData, pod.GetNamespace()+"/"+pod.GetName())
+		}
+		evict = append(evict, pod)
+	}
+
+	if len(localData) > 0 {
+		return nil, fmt.Errorf("failed to drain node %s, pods with local data: %s", name, strings.Join(localData, ", "))
+	}
+
+	log.Printf("Cordon node %s", name)
+	if err := nm.CordonNode(name); err != nil {
+		return nil, errors.Wrap(err, "failed to drain node")
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	parallel := options.MaxParallel
+	if parallel <= 0 || parallel > len(evict) {
+		parallel = len(evict)
+	}
+
+	deleteOptions := &metav1.DeleteOptions{GracePeriodSeconds: options.GracePeriod}
+	results := make([]DrainPodResult, len(evict))
+	semaphore := make(chan struct{}, parallel)
+	var waitGroup sync.WaitGroup
+
+	for i, pod := range evict {
+		waitGroup.Add(1)
+		go func(i int, pod v1.Pod) {
+			defer waitGroup.Done()
+
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[i] = DrainPodResult{Namespace: pod.GetNamespace(), Name: pod.GetName(), Err: ctx.Err()}
+				return
+			}
+
+			// Blocked evictions are retried until the drain times out
+			var eviction EvictionOptions
+			if deadline, ok := ctx.Deadline(); ok {
+				eviction.Timeout = time.Until(deadline)
+			}
+
+			start := time.Now()
+			evicted, err := evictPod(ctx, nm.client, pod.GetName(), pod.GetNamespace(), deleteOptions, eviction)
+			results[i] = DrainPodResult{
+				Namespace: pod.GetNamespace(),
+				Name:      pod.GetName(),
+				Duration:  time.Since(start),
+				Eviction:  evicted,
+				Err:       err,
+			}
+		}(i, pod)
+	}
+
+	waitGroup.Wait()
+	result.Pods = append(result.Pods, results...)
+
+	var errorList []error
+	for _, p := range results {
+		if p.Err != nil {
+			errorList = append(errorList, errors.Wrapf(p.Err, "failed to evict %s/%s", p.Namespace, p.Name))
+		}
+	}
+
+	if len(errorList) != 0 {
+		return result, errors.Wrap(k8serrors.NewAggregate(errorList), "failed to drain node")
+	}
+	return result, nil
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	driver "github.com/arangodb/go-driver"
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// dryRunMaxSteps stops plans that would never end, e.g. a repeat
+	// block limited by duration whose actions take no time
+	dryRunMaxSteps = 10000
+	// dryRunHealthEstimate is the time assumed for waiting for health
+	dryRunHealthEstimate = time.Minute
+)
+
+// dryRunPlanner counts and prints the planned operations of all branches
+type dryRunPlanner struct {
+	mutex sync.Mutex
+	start time.Time
+	steps int
+}
+
+// dryRunEnvironment resolves all targets against the live cluster, but only
+// prints the operations it would perform. Time passes virtually, delays do
+// not block. Concurrent branches have their own clock, see Fork.
+type dryRunEnvironment struct {
+	real    ActionInterface
+	planner *dryRunPlanner
+
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewDryRunEnvironment wraps the given environment into one that does not
+// issue any mutating call
+func NewDryRunEnvironment(real ActionInterface) ActionInterface {
+	now := time.Now()
+	return &dryRunEnvironment{
+		real:    real,
+		planner: &dryRunPlanner{start: now},
+		now:     now,
+	}
+}
+
+// Fork returns an environment for a concurrent branch, whose clock starts
+// at the current time of e and advances independently
+func (e *dryRunEnvironment) Fork() ActionInterface {
+	return &dryRunEnvironment{
+		real:    e.real,
+		planner: e.planner,
+		now:     e.Now(),
+	}
+}
+
+// Join advances the clock to the end of the latest branch
+func (e *dryRunEnvironment) Join(branches []ActionInterface) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, branch := range branches {
+		if b, ok := branch.(*dryRunEnvironment); ok {
+			if now := b.Now(); now.After(e.now) {
+				e.now = now
+			}
+		}
+	}
+}
+
+// plan prints a planned operation with its offset from the start of the run
+func (e *dryRunEnvironment) plan(format string, args ...interface{}) error {
+	now := e.Now()
+
+	p := e.planner
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.steps++
+	if p.steps > dryRunMaxSteps {
+		return errors.Errorf("dry run exceeds %d planned steps", dryRunMaxSteps)
+	}
+
+	offset := now.Sub(p.start).Round(time.Second)
+	fmt.Printf("[+%s] %s\n", offset, fmt.Sprintf(format, args...))
+	return nil
+}
+
+func (e *dryRunEnvironment) advance(d time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.now = e.now.Add(d)
+}
+
+func (e *dryRunEnvironment) Nodes() NodeManager {
+	return &dryRunNodeManager{env: e}
+}
+
+func (e *dryRunEnvironment) Pods() PodManager {
+	return &dryRunPodManager{env: e}
+}
+
+func (e *dryRunEnvironment) Deployment() DeploymentManager {
+	return &dryRunDeploymentManager{env: e}
+}
+
+func (e *dryRunEnvironment) Operator() Operator {
+	return &dryRunOperator{env: e}
+}
+
+func (e *dryRunEnvironment) Cleanups() *CleanupRegistry {
+	return e.real.Cleanups()
+}
+
+func (e *dryRunEnvironment) WaitForHealth(ctx context.Context) error {
+	if err := e.plan("Wait for health of all deployments (assuming %s)", dryRunHealthEstimate); err != nil {
+		return err
+	}
+	e.advance(dryRunHealthEstimate)
+	return nil
+}
+
+func (e *dryRunEnvironment) Now() time.Time {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.now
+}
+
+func (e *dryRunEnvironment) Sleep(ctx context.Context, d time.Duration) error {
+	if err := e.plan("Wait %s", d); err != nil {
+		return err
+	}
+	e.advance(d)
+	return nil
+}
+
+// complete reports success on the completion channel like the real pod operations do
+func complete(completion chan<- error) {
+	go func() {
+		completion <- nil
+	}()
+}
+
+type dryRunNodeManager struct {
+	env *dryRunEnvironment
+}
+
+func (nm *dryRunNodeManager) Node(name string) Node {
+	return &dryRunNode{env: nm.env, real: nm.env.real.Nodes().Node(name)}
+}
+
+func (nm *dryRunNodeManager) Allowed(name string) error {
+	return nm.env.real.Nodes().Allowed(name)
+}
+
+func (nm *dryRunNodeManager) Usable() ([]string, error) {
+	return nm.env.real.Nodes().Usable()
+}
+
+func (nm *dryRunNodeManager) Zones(label string) (map[string][]string, error) {
+	return nm.env.real.Nodes().Zones(label)
+}
+
+type dryRunNode struct {
+	env  *dryRunEnvironment
+	real Node
+}
+
+func (n *dryRunNode) Name() string {
+	return n.real.Name()
+}
+
+func (n *dryRunNode) Cordon() error {
+	return n.env.plan("Cordon node %s", n.Name())
+}
+
+func (n *dryRunNode) Uncordon() error {
+	return n.env.plan("Uncordon node %s", n.Name())
+}
+
+func (n *dryRunNode) IsCordoned() (bool, error) {
+	return n.real.IsCordoned()
+}
+
+func (n *dryRunNode) Drain(ctx context.Context, options DrainOptions) (*DrainResult, error) {
+	gracePeriod := "default"
+	if options.GracePeriod != nil {
+		gracePeriod = fmt.Sprintf("%ds", *options.GracePeriod)
+	}
+
+	if err := n.env.plan("Drain node %s (grace period %s, timeout %s, max parallel %d, delete local data %t)",
+		n.Name(), gracePeriod, options.Timeout, options.MaxParallel, options.DeleteLocalData); err != nil {
+		return nil, err
+	}
+	return &DrainResult{Node: n.Name()}, nil
+}
+
+func (n *dryRunNode) Kill(ctx context.Context) error {
+	return n.env.plan("Kill node %s", n.Name())
+}
+
+func (n *dryRunNode) Restore(ctx context.Context) error {
+	return n.env.plan("Restore node %s", n.Name())
+}
+
+type dryRunPodManager struct {
+	env *dryRunEnvironment
+}
+
+func (pm *dryRunPodManager) Pod(name string) Pod {
+	return &dryRunPod{env: pm.env, real: pm.env.real.Pods().Pod(name)}
+}
+
+func (pm *dryRunPodManager) Target(ctx context.Context, target PodTarget) (Pod, error) {
+	real, err := pm.env.real.Pods().Target(ctx, target)
+	if err != nil || real == nil {
+		return nil, err
+	}
+
+	if err := pm.env.plan("Resolved target %+v to pod %s/%s", target, real.Namespace(), real.Name()); err != nil {
+		return nil, err
+	}
+	return &dryRunPod{env: pm.env, real: real}, nil
+}
+
+func (pm *dryRunPodManager) TargetCandidates(ctx context.Context, target PodTarget) ([]string, error) {
+	return pm.env.real.Pods().TargetCandidates(ctx, target)
+}
+
+type dryRunPod struct {
+	env  *dryRunEnvironment
+	real Pod
+
+	// restarts are the container restarts the planned signals would cause
+	restarts int32
+}
+
+func (p *dryRunPod) Name() string {
+	return p.real.Name()
+}
+
+func (p *dryRunPod) Namespace() string {
+	return p.real.Namespace()
+}
+
+func (p *dryRunPod) Evict(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions, eviction EvictionOptions) error {
+	if err := p.env.plan("Evict pod %s/%s (timeout %s, force delete %t)", p.Namespace(), p.Name(), eviction.Timeout, eviction.ForceDelete); err != nil {
+		return err
+	}
+	complete(completion)
+	return nil
+}
+
+func (p *dryRunPod) Delete(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions) error {
+	if err := p.env.plan("Delete pod %s/%s", p.Namespace(), p.Name()); err != nil {
+		return err
+	}
+	complete(completion)
+	return nil
+}
+
+func (p *dryRunPod) DeletePersistentVolumeClaims(ctx context.Context, completion chan<- error, removeFinalizer bool, options *metav1.DeleteOptions) error {
+	if err := p.env.plan("Delete PVCs of pod %s/%s (remove finalizer %t)", p.Namespace(), p.Name(), removeFinalizer); err != nil {
+		return err
+	}
+	complete(completion)
+	return nil
+}
+
+func (p *dryRunPod) Isolate(ctx context.Context, peers []string) error {
+	return p.env.plan("Isolate pod %s/%s from %v", p.Namespace(), p.Name(), peers)
+}
+
+func (p *dryRunPod) Reconnect(ctx context.Context) error {
+	return p.env.plan("Reconnect pod %s/%s", p.Namespace(), p.Name())
+}
+
+func (p *dryRunPod) Netem(ctx context.Context, options NetemOptions) error {
+	helper := "exec"
+	if options.HelperImage != "" {
+		helper = "helper " + options.HelperImage
+	}
+	return p.env.plan("Add %s to pod %s/%s (%s)", options, p.Namespace(), p.Name(), helper)
+}
+
+func (p *dryRunPod) ClearNetem(ctx context.Context, options NetemOptions) error {
+	return p.env.plan("Remove network emulation from pod %s/%s", p.Namespace(), p.Name())
+}
+
+func (p *dryRunPod) Freeze(ctx context.Context, image string, deadline time.Duration) error {
+	return p.env.plan("Freeze pod %s/%s (helper %s)", p.Namespace(), p.Name(), freezeHelper(image, deadline).Image)
+}
+
+func (p *dryRunPod) Thaw(ctx context.Context) error {
+	return p.env.plan("Thaw pod %s/%s", p.Namespace(), p.Name())
+}
+
+func (p *dryRunPod) Signal(ctx context.Context, signal ProcessSignal, helperImage string) error {
+	if err := p.env.plan("Send SIG%s to pod %s/%s", signal, p.Namespace(), p.Name()); err != nil {
+		return err
+	}
+	p.restarts++
+	return nil
+}
+
+func (p *dryRunPod) RestartCount() (int32, bool, error) {
+	count, running, err := p.real.RestartCount()
+	return count + p.restarts, running, err
+}
+
+func (p *dryRunPod) FillVolume(ctx context.Context, percent int) error {
+	return p.env.plan("Fill data volume of pod %s/%s to %d%%", p.Namespace(), p.Name(), percent)
+}
+
+func (p *dryRunPod) FreeVolume(ctx context.Context) error {
+	return p.env.plan("Free data volume of pod %s/%s", p.Namespace(), p.Name())
+}
+
+func (p *dryRunPod) UID() (types.UID, error) {
+	return p.real.UID()
+}
+
+func (p *dryRunPod) WaitForReplacement(ctx context.Context, uid types.UID) error {
+	return p.env.plan("Wait for pod %s/%s to be replaced", p.Namespace(), p.Name())
+}
+
+func (p *dryRunPod) Deployment() (string, error) {
+	return p.real.Deployment()
+}
+
+func (p *dryRunPod) Node() (Node, error) {
+	real, err := p.real.Node()
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunNode{env: p.env, real: real}, nil
+}
+
+type dryRunDeploymentManager struct {
+	env *dryRunEnvironment
+}
+
+func (dm *dryRunDeploymentManager) Deployment(name string) Deployment {
+	return &dryRunDeployment{env: dm.env, name: name, real: dm.env.real.Deployment().Deployment(name)}
+}
+
+func (dm *dryRunDeploymentManager) Allowed(name string) error {
+	return dm.env.real.Deployment().Allowed(name)
+}
+
+func (dm *dryRunDeploymentManager) New(ctx context.Context, name string, spec arangoapi.DeploymentSpec) (Deployment, error) {
+	if err := dm.env.plan("Create deployment %s (mode %s)", name, spec.GetMode()); err != nil {
+		return nil, err
+	}
+	return dm.Deployment(name), nil
+}
+
+type dryRunDeployment struct {
+	env  *dryRunEnvironment
+	name string
+	real Deployment
+}
+
+func (d *dryRunDeployment) Delete(ctx context.Context) error {
+	return d.env.plan("Delete deployment %s", d.name)
+}
+
+func (d *dryRunDeployment) Database(ctx context.Context) (driver.Client, error) {
+	return d.real.Database(ctx)
+}
+
+func (d *dryRunDeployment) WaitForReady(ctx context.Context) error {
+	if err := d.env.plan("Wait for deployment %s to become ready (assuming %s)", d.name, dryRunHealthEstimate); err != nil {
+		return err
+	}
+	d.env.advance(dryRunHealthEstimate)
+	return nil
+}
+
+type dryRunOperator struct {
+	env *dryRunEnvironment
+}
+
+func (o *dryRunOperator) Deploy(ctx context.Context, image string) error {
+	return o.env.plan("Deploy operator %s", image)
+}
+
+func (o *dryRunOperator) Delete(ctx context.Context, deleteCRD bool) error {
+	return o.env.plan("Delete operator (delete CRD %t)", deleteCRD)
+}
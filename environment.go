@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
+	"github.com/pkg/errors"
+	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// EnvironmentConfig contains the clients and settings of an environment
+type EnvironmentConfig struct {
+	// Config is used to exec into pods
+	Config *rest.Config
+	Client k8s.Interface
+	Arango arangoclient.DatabaseV1alphaInterface
+	API    apiextension.Interface
+	// Namespaces contains the ArangoDeployments targeted by chaos.
+	// Deployments and the operator are created in the first namespace.
+	Namespaces []string
+	Health     *healthChecker
+	Terminator NodeTerminator
+	Selector   TargetSelector
+	// HealthTimeout limits how long WaitForHealth waits
+	HealthTimeout time.Duration
+	// NodeRefresh is the interval the usable nodes are refreshed at
+	NodeRefresh time.Duration
+}
+
+// environment is the ActionInterface used when running actions against a cluster
+type environment struct {
+	client        k8s.Interface
+	arango        arangoclient.DatabaseV1alphaInterface
+	namespaces    []string
+	health        *healthChecker
+	healthTimeout time.Duration
+
+	nodes       NodeManager
+	pods        PodManager
+	deployments DeploymentManager
+	operator    Operator
+	cleanups    *CleanupRegistry
+}
+
+// NewEnvironment creates an action environment for the configured namespaces
+func NewEnvironment(config EnvironmentConfig) (ActionInterface, error) {
+	if len(config.Namespaces) == 0 {
+		return nil, errors.New("no namespace given")
+	}
+
+	nodes, err := NewNodeManager(config.Client, config.Terminator, config.Selector,
+		NewNodePolicy(config.Client, config.Namespaces, config.Selector, config.NodeRefresh))
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := NewPodManager(config.Config, config.Client, config.Arango, config.Namespaces, nodes, config.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := NewDeploymentManager(config.Client, config.Arango, config.Namespaces[0], config.Health, config.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	operator, err := NewOperator(config.Client, config.API, config.Namespaces[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &environment{
+		client:        config.Client,
+		arango:        config.Arango,
+		namespaces:    config.Namespaces,
+		health:        config.Health,
+		healthTimeout: config.HealthTimeout,
+		nodes:         nodes,
+		pods:          pods,
+		deployments:   deployments,
+		operator:      operator,
+		cleanups:      NewCleanupRegistry(),
+	}, nil
+}
+
+func (e *environment) Nodes() NodeManager {
+	return e.nodes
+}
+
+func (e *environment) Pods() PodManager {
+	return e.pods
+}
+
+func (e *environment) Deployment() DeploymentManager {
+	return e.deployments
+}
+
+func (e *environment) Operator() Operator {
+	return e.operator
+}
+
+func (e *environment) Cleanups() *CleanupRegistry {
+	return e.cleanups
+}
+
+// WaitForHealth waits until all deployments of the namespaces are ready
+func (e *environment) WaitForHealth(ctx context.Context) error {
+	if e.healthTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.healthTimeout)
+		defer cancel()
+	}
+
+	return e.health.WaitForDeploymentsReady(ctx)
+}
+
+func (e *environment) Now() time.Time {
+	return time.Now()
+}
+
+func (e *environment) Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -1,77 +1,89 @@
 package main
 
-/*
-type ExecutionContext interface {
-	Nodes() NodeManager
-	Pods() PodManager
-}
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+)
 
+// Executor runs action scripts
 type Executor struct {
+	iface ActionInterface
 }
 
-func CreateAction(desc ActionDescription) (Action, error) {
-	return nil, nil
+// NewExecutor creates an executor running actions using the given interface
+func NewExecutor(iface ActionInterface) *Executor {
+	return &Executor{
+		iface: iface,
+	}
 }
 
-func (e *Executor) Run(ctx context.Context, script ActionDescriptionList) error {
+// actionStep is an action together with the description controlling its execution
+type actionStep struct {
+	desc   ActionDescription
+	action Action
+}
 
-	for _, desc := range script {
-		action, err := CreateAction(desc)
+// newActionSteps creates the actions of the list
+func newActionSteps(path string, list ActionDescriptionList) ([]actionStep, error) {
+	steps := make([]actionStep, len(list))
+	for i, desc := range list {
+		action, err := NewAction(desc)
 		if err != nil {
-			return err
+			return nil, errors.Wrapf(err, "%s[%d]", path, i)
 		}
+		steps[i] = actionStep{desc: desc, action: action}
+	}
+	return steps, nil
+}
+
+// run executes the action, waits for the configured delay and, if
+// requested, for the health of the cluster
+func (s actionStep) run(ctx context.Context, iface ActionInterface, path string) error {
+	desc := s.desc
 
-		log.Printf("Starting action %s", desc.Type)
-		if err := action.Start(); err != nil {
+	log.Printf("Starting action %s: %s", path, desc.Type)
+	if err := s.action.Run(ctx, iface); err != nil {
+		return errors.Wrapf(err, "action %s (%s) failed", path, desc.Type)
+	}
+
+	if desc.Delay > 0 {
+		log.Printf("Waiting %s after action %s", desc.Delay, path)
+		if err := iface.Sleep(ctx, desc.Delay); err != nil {
 			return err
 		}
+	}
 
-		if desc.WaitForCompletion {
-			log.Printf("Waiting for action completion")
-		waitForCompletionLoop:
-			for {
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case <-time.After(desc.Delay):
-					progress, err := action.CheckProgress()
-					if err != nil {
-						return err
-					}
+	if desc.WaitForHealth {
+		log.Printf("Waiting for cluster health")
+		if err := iface.WaitForHealth(ctx); err != nil {
+			return errors.Wrapf(err, "waiting for cluster health after action %s (%s) failed", path, desc.Type)
+		}
+	}
 
-					if progress.IsCompleted() {
-						break waitForCompletionLoop
-					}
+	return nil
+}
 
-					if progress.IsFailed() {
-						return errors.New("Action failed")
-					}
-				}
-			}
-		} else {
-			time.Sleep(desc.Delay)
+// runActionSteps runs the steps in order and stops at the first failure
+func runActionSteps(ctx context.Context, iface ActionInterface, path string, steps []actionStep) error {
+	for i, step := range steps {
+		if err := step.run(ctx, iface, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if desc.WaitForHealth {
-			log.Printf("Waiting for cluster health")
-
-			timeout, cancel := context.WithTimeout(ctx, 2*time.Minute)
+// Run executes the actions in order and stops at the first failure. Actions
+// that do not wait for completion only log asynchronous failures.
+func (e *Executor) Run(ctx context.Context, script ActionDescriptionList) error {
 
-		waitForHealthLoop:
-			for {
-				select {
-				case <-timeout.Done():
-					cancel()
-					return errors.Wrap(ctx.Err(), "waiting for cluster health failed")
-				case <-time.After(20 * time.Second):
-					// check cluster health
-					cancel()
-					break waitForHealthLoop
-				}
-			}
-		}
+	steps, err := newActionSteps("actions", script)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return runActionSteps(ctx, e.iface, "actions", steps)
 }
-*/
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+type FaultKind string
+
+const (
+	FaultKindDeletePod FaultKind = "DeletePod"
+	FaultKindDrainNode FaultKind = "DrainNode"
+	FaultKindKillNode  FaultKind = "KillNode"
+	// FaultKindZoneOutage takes down all nodes of a random zone
+	FaultKindZoneOutage FaultKind = "ZoneOutage"
+	// FaultKindPartition cuts the network of a member off its peers
+	FaultKindPartition FaultKind = "Partition"
+	// FaultKindNetem adds latency and packet loss to the network of a member
+	FaultKindNetem FaultKind = "Netem"
+	// FaultKindFreeze stops the arangod process of a member for a while
+	FaultKindFreeze FaultKind = "Freeze"
+	// FaultKindKillProcess kills the arangod process of a member, which
+	// restarts its container instead of recreating the pod
+	FaultKindKillProcess FaultKind = "KillProcess"
+	// FaultKindDiskFull fills the data volume of an agent or dbserver
+	FaultKindDiskFull FaultKind = "DiskFull"
+)
+
+// IntRange is an inclusive range of integers
+type IntRange struct {
+	Min int64 `json:"min"`
+	Max int64 `json:"max"`
+}
+
+// Random returns a random value of the range
+func (r IntRange) Random() int64 {
+	return r.Min + rand.Int63n(r.Max-r.Min+1)
+}
+
+func (r IntRange) Validate() error {
+	if r.Min < 0 {
+		return withPath("min", fmt.Errorf("must not be negative"))
+	}
+	if r.Max < r.Min {
+		return withPath("max", fmt.Errorf("must not be less than min"))
+	}
+	return nil
+}
+
+// FaultConfig describes one kind of fault of the random chaos. Faults are
+// picked according to their weights.
+type FaultConfig struct {
+	Kind     FaultKind `json:"kind"`
+	Weight   int       `json:"weight"`
+	Disabled bool      `json:"disabled,omitempty"`
+	// Groups are the pod groups the faults targeting a member pick from
+	Groups []PodGroup `json:"groups,omitempty"`
+	// GracePeriod in seconds of DeletePod and DrainNode, the default grace
+	// period of the pods is used if not set
+	GracePeriod *IntRange `json:"gracePeriod,omitempty"`
+	// ZoneLabel is the node label ZoneOutage groups nodes by
+	ZoneLabel string `json:"zoneLabel,omitempty"`
+	// Mode tells whether ZoneOutage kills or drains the nodes
+	Mode ZoneOutageMode `json:"mode,omitempty"`
+	// Outage is the time until ZoneOutage restores the nodes
+	Outage Duration `json:"outage,omitempty"`
+	// Leader makes Partition isolate the agency leader
+	Leader bool `json:"leader,omitempty"`
+	// From are the pod groups Partition cuts the member off, all other
+	// members of the deployment if not set
+	From []PodGroup `json:"from,omitempty"`
+	// Latency, Jitter and Loss in percent are added to the network by Netem
+	Latency Duration `json:"latency,omitempty"`
+	Jitter  Duration `json:"jitter,omitempty"`
+	Loss    float64  `json:"loss,omitempty"`
+	// HelperImage makes Netem run tc in a privileged helper pod, and
+	// replaces the busybox image of the Freeze and KillProcess helpers
+	HelperImage string `json:"helperImage,omitempty"`
+	// Signal is sent by KillProcess
+	Signal ProcessSignal `json:"signal,omitempty"`
+	// Percent of its size DiskFull fills the volume to
+	Percent int `json:"percent,omitempty"`
+	// Duration is the time until Partition is healed, the Netem emulation
+	// is removed, the frozen process is continued or the volume is freed
+	Duration Duration `json:"duration,omitempty"`
+}
+
+func (f FaultConfig) Validate() error {
+	generator, ok := faultGenerators[f.Kind]
+	if !ok {
+		return withPath("kind", fmt.Errorf("unknown fault kind %q", f.Kind))
+	}
+	if f.Weight < 0 {
+		return withPath("weight", fmt.Errorf("must not be negative"))
+	}
+	if f.GracePeriod != nil {
+		if !generator.gracePeriod {
+			return withPath("gracePeriod", fmt.Errorf("not supported for %s", f.Kind))
+		}
+		if err := f.GracePeriod.Validate(); err != nil {
+			return withPath("gracePeriod", err)
+		}
+	}
+	if len(f.Groups) > 0 && !generator.groups {
+		return withPath("groups", fmt.Errorf("not supported for %s", f.Kind))
+	}
+	if !generator.zone && (f.ZoneLabel != "" || f.Mode != "" || f.Outage != 0) {
+		return fmt.Errorf("zoneLabel, mode and outage are not supported for %s", f.Kind)
+	}
+	if generator.zone {
+		if err := f.Mode.Validate(); err != nil {
+			return withPath("mode", err)
+		}
+		if f.Outage <= 0 {
+			return withPath("outage", fmt.Errorf("must be positive"))
+		}
+	}
+	if !generator.partition && (f.Leader || len(f.From) > 0) {
+		return fmt.Errorf("leader and from are not supported for %s", f.Kind)
+	}
+	if !generator.netem && (f.Latency != 0 || f.Jitter != 0 || f.Loss != 0) {
+		return fmt.Errorf("latency, jitter and loss are not supported for %s", f.Kind)
+	}
+	if !generator.helper && f.HelperImage != "" {
+		return withPath("helperImage", fmt.Errorf("not supported for %s", f.Kind))
+	}
+	if generator.netem {
+		if err := validateNetem(f.Latency, f.Jitter, f.Loss); err != nil {
+			return err
+		}
+	}
+	if generator.signal {
+		if err := f.Signal.Validate(); err != nil {
+			return withPath("signal", err)
+		}
+		if f.HelperImage != "" && f.Signal != SignalKill {
+			return withPath("helperImage", fmt.Errorf("only used for signal %s", SignalKill))
+		}
+	} else if f.Signal != "" {
+		return withPath("signal", fmt.Errorf("not supported for %s", f.Kind))
+	}
+	if generator.disk {
+		if err := validateFillPercent(f.Percent); err != nil {
+			return err
+		}
+	} else if f.Percent != 0 {
+		return withPath("percent", fmt.Errorf("not supported for %s", f.Kind))
+	}
+	if generator.duration != (f.Duration != 0) {
+		if f.Duration == 0 {
+			return withPath("duration", fmt.Errorf("required"))
+		}
+		return withPath("duration", fmt.Errorf("not supported for %s", f.Kind))
+	}
+	if f.Duration < 0 {
+		return withPath("duration", fmt.Errorf("must be positive"))
+	}
+	for i, group := range f.Groups {
+		if !group.IsValid() {
+			return withPath(fmt.Sprintf("groups[%d]", i), fmt.Errorf("unknown pod group %q", group))
+		}
+		if (generator.duration || generator.signal) && group == PodGroupOperator {
+			return withPath(fmt.Sprintf("groups[%d]", i), fmt.Errorf("must be a server group"))
+		}
+		if generator.disk && group == PodGroupCoordinator {
+			return withPath(fmt.Sprintf("groups[%d]", i), fmt.Errorf("%s has no data volume", group))
+		}
+		if f.Leader && group != PodGroupAgent {
+			return withPath(fmt.Sprintf("groups[%d]", i), fmt.Errorf("leader requires group %s", PodGroupAgent))
+		}
+	}
+	for i, group := range f.From {
+		if !group.IsValid() || group == PodGroupOperator {
+			return withPath(fmt.Sprintf("from[%d]", i), fmt.Errorf("unknown server group %q", group))
+		}
+	}
+	return nil
+}
+
+// gracePeriod returns a random grace period of the configured range or nil
+func (f FaultConfig) gracePeriod() *int64 {
+	if f.GracePeriod == nil {
+		return nil
+	}
+	gracePeriod := f.GracePeriod.Random()
+	return &gracePeriod
+}
+
+// FaultCatalogue lists the faults of the random chaos
+type FaultCatalogue struct {
+	Faults []FaultConfig `json:"faults"`
+}
+
+func (c *FaultCatalogue) Validate() error {
+	total := 0
+	for i, fault := range c.Faults {
+		if err := fault.Validate(); err != nil {
+			return withPath(fmt.Sprintf("faults[%d]", i), err)
+		}
+		if !fault.Disabled {
+			total += fault.Weight
+		}
+	}
+	if total == 0 {
+		return withPath("faults", fmt.Errorf("at least one enabled fault needs a positive weight"))
+	}
+	return nil
+}
+
+// DefaultFaultCatalogue returns the faults used without configuration
+func DefaultFaultCatalogue() *FaultCatalogue {
+	return &FaultCatalogue{
+		Faults: []FaultConfig{
+			{
+				Kind:        FaultKindDeletePod,
+				Weight:      3,
+				Groups:      []PodGroup{PodGroupAgent, PodGroupCoordinator, PodGroupDBServer},
+				GracePeriod: &IntRange{Min: 0, Max: 0},
+			},
+			{Kind: FaultKindDrainNode, Weight: 2},
+			{Kind: FaultKindDrainNode, Weight: 1, GracePeriod: &IntRange{Min: 0, Max: 0}},
+			{Kind: FaultKindDrainNode, Weight: 3, GracePeriod: &IntRange{Min: 10, Max: 209}},
+			{Kind: FaultKindKillNode, Weight: 2},
+		},
+	}
+}
+
+// LoadFaultCatalogue reads and validates a fault catalogue from a JSON or YAML file
+func LoadFaultCatalogue(path string) (*FaultCatalogue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fault catalogue")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse fault catalogue")
+		}
+	}
+
+	var catalogue FaultCatalogue
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&catalogue); err != nil {
+		return nil, errors.Wrap(err, "failed to parse fault catalogue")
+	}
+
+	if err := catalogue.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid fault catalogue")
+	}
+
+	return &catalogue, nil
+}
+
+// pick returns a random enabled fault according to the weights
+func (c *FaultCatalogue) pick() FaultConfig {
+	total := 0
+	for _, fault := range c.Faults {
+		if !fault.Disabled {
+			total += fault.Weight
+		}
+	}
+
+	n := rand.Intn(total)
+	for _, fault := range c.Faults {
+		if fault.Disabled {
+			continue
+		}
+		if n < fault.Weight {
+			return fault
+		}
+		n -= fault.Weight
+	}
+	return c.Faults[len(c.Faults)-1]
+}
+
+// Generate picks a random fault and returns the actions causing it and
+// cleaning up after it. Pods and nodes are resolved, so that the actions
+// can be replayed exactly. Both are nil if no target was found.
+func (c *FaultCatalogue) Generate(ctx context.Context, env ActionInterface) (*ActionDescription, *ActionDescription) {
+	fault := c.pick()
+
+	cleanup, chaos, err := faultGenerators[fault.Kind].generate(ctx, env, fault)
+	if err != nil {
+		log.Printf("Failed to generate %s fault: %s", fault.Kind, err.Error())
+		return nil, nil
+	}
+
+	return cleanup, chaos
+}
+
+// faultGenerator creates the actions of a kind of fault
+type faultGenerator struct {
+	// gracePeriod, groups, zone, partition, netem, helper, signal, disk
+	// and duration tell which options of FaultConfig are supported
+	gracePeriod bool
+	groups      bool
+	zone        bool
+	partition   bool
+	netem       bool
+	helper      bool
+	signal      bool
+	disk        bool
+	duration    bool
+	generate    func(ctx context.Context, env ActionInterface, fault FaultConfig) (cleanup, chaos *ActionDescription, err error)
+}
+
+var faultGenerators = map[FaultKind]faultGenerator{
+	FaultKindDeletePod:   {gracePeriod: true, groups: true, generate: generateDeletePod},
+	FaultKindDrainNode:   {gracePeriod: true, generate: generateDrainNode},
+	FaultKindKillNode:    {generate: generateKillNode},
+	FaultKindZoneOutage:  {zone: true, generate: generateZoneOutage},
+	FaultKindPartition:   {groups: true, partition: true, duration: true, generate: generatePartition},
+	FaultKindNetem:       {groups: true, netem: true, helper: true, duration: true, generate: generateNetem},
+	FaultKindFreeze:      {groups: true, helper: true, duration: true, generate: generateFreeze},
+	FaultKindKillProcess: {groups: true, helper: true, signal: true, generate: generateKillProcess},
+	FaultKindDiskFull:    {groups: true, disk: true, duration: true, generate: generateDiskFull},
+}
+
+// randomNode returns a target for a random usable node
+func randomNode(env ActionInterface) (NodeTarget, error) {
+	nodes, err := env.Nodes().Usable()
+	if err != nil {
+		return NodeTarget{}, err
+	}
+	if len(nodes) == 0 {
+		return NodeTarget{}, errors.New("no usable nodes")
+	}
+	return NodeTarget{Name: nodes[rand.Intn(len(nodes))]}, nil
+}
+
+// randomPod returns a random member of the groups, of all server groups if
+// none are given. With leader set the agency leader is returned.
+func randomPod(ctx context.Context, env ActionInterface, groups []PodGroup, leader bool) (Pod, error) {
+	if len(groups) == 0 {
+		groups = []PodGroup{PodGroupAgent, PodGroupCoordinator, PodGroupDBServer}
+	}
+	if leader {
+		groups = []PodGroup{PodGroupAgent}
+	}
+	return targetPod(ctx, env, PodTarget{Group: groups[rand.Intn(len(groups))], IsLeader: leader})
+}
+
+func generateDeletePod(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	pod, err := randomPod(ctx, env, fault.Groups, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Deleting pod %s/%s", pod.Namespace(), pod.Name())
+	return nil, &ActionDescription{
+		Type: ActionTypeDeletePod,
+		DeletePod: &ActionDeletePodDescription{
+			Target:            PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			WaitForCompletion: true,
+			GracePeriod:       fault.gracePeriod(),
+		},
+	}, nil
+}
+
+func generateDrainNode(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	target, err := randomNode(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gracePeriod := fault.gracePeriod()
+	if gracePeriod != nil {
+		log.Printf("Draining node %s, with grace-period %d", target.Name, *gracePeriod)
+	} else {
+		log.Printf("Draining node %s", target.Name)
+	}
+
+	return &ActionDescription{
+		Type:         ActionTypeUncordonNode,
+		UncordonNode: &ActionUncordonNodeDescription{Target: target},
+	}, &ActionDescription{
+		Type: ActionTypeDrainNode,
+		DrainNode: &ActionDrainNodeDescription{
+			Target:          target,
+			DeleteLocalData: true,
+			GracePeriod:     gracePeriod,
+		},
+	}, nil
+}
+
+func generateKillNode(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	target, err := randomNode(env)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Killing node %s", target.Name)
+	return &ActionDescription{
+		Type:        ActionTypeRestoreNode,
+		RestoreNode: &ActionRestoreNodeDescription{Target: target},
+	}, &ActionDescription{
+		Type:     ActionKillNode,
+		KillNode: &ActionKillNodeDescription{Target: target},
+	}, nil
+}
+
+func generateZoneOutage(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	label := fault.ZoneLabel
+	if label == "" {
+		label = defaultZoneLabel
+	}
+
+	zones, err := env.Nodes().Zones(label)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(zones) < 2 {
+		return nil, nil, errors.Errorf("found %d zones with label %s, at least 2 are required", len(zones), label)
+	}
+
+	var names []string
+	for zone := range zones {
+		names = append(names, zone)
+	}
+	sort.Strings(names)
+	zone := names[rand.Intn(len(names))]
+
+	log.Printf("Taking down zone %s with nodes %v", zone, zones[zone])
+	return nil, &ActionDescription{
+		Type: ActionTypeZoneOutage,
+		ZoneOutage: &ActionZoneOutageDescription{
+			Zone:      zone,
+			ZoneLabel: label,
+			Nodes:     zones[zone],
+			Mode:      fault.Mode,
+			Outage:    fault.Outage,
+		},
+	}, nil
+}
+
+func generatePartition(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	pod, err := randomPod(ctx, env, fault.Groups, fault.Leader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Partitioning pod %s/%s from %v", pod.Namespace(), pod.Name(), fault.From)
+	return nil, &ActionDescription{
+		Type: ActionTypePartition,
+		Partition: &ActionPartitionDescription{
+			Target:   PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			From:     fault.From,
+			Duration: fault.Duration,
+		},
+	}, nil
+}
+
+func generateNetem(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	pod, err := randomPod(ctx, env, fault.Groups, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Degrading network of pod %s/%s", pod.Namespace(), pod.Name())
+	return nil, &ActionDescription{
+		Type: ActionTypeNetem,
+		Netem: &ActionNetemDescription{
+			Target:      PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			Latency:     fault.Latency,
+			Jitter:      fault.Jitter,
+			Loss:        fault.Loss,
+			HelperImage: fault.HelperImage,
+			Duration:    fault.Duration,
+		},
+	}, nil
+}
+
+func generateFreeze(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	pod, err := randomPod(ctx, env, fault.Groups, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Freezing pod %s/%s for %s", pod.Namespace(), pod.Name(), time.Duration(fault.Duration))
+	return nil, &ActionDescription{
+		Type: ActionTypeFreeze,
+		Freeze: &ActionFreezeDescription{
+			Target:      PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			HelperImage: fault.HelperImage,
+			Duration:    fault.Duration,
+		},
+	}, nil
+}
+
+func generateKillProcess(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	pod, err := randomPod(ctx, env, fault.Groups, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Sending SIG%s to pod %s/%s", fault.Signal, pod.Namespace(), pod.Name())
+	return nil, &ActionDescription{
+		Type: ActionTypeKillProcess,
+		KillProcess: &ActionKillProcessDescription{
+			Target:      PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			Signal:      fault.Signal,
+			HelperImage: fault.HelperImage,
+		},
+	}, nil
+}
+
+func generateDiskFull(ctx context.Context, env ActionInterface, fault FaultConfig) (*ActionDescription, *ActionDescription, error) {
+	groups := fault.Groups
+	if len(groups) == 0 {
+		groups = []PodGroup{PodGroupAgent, PodGroupDBServer}
+	}
+
+	pod, err := randomPod(ctx, env, groups, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Printf("Filling data volume of pod %s/%s to %d%%", pod.Namespace(), pod.Name(), fault.Percent)
+	return nil, &ActionDescription{
+		Type: ActionTypeDiskFull,
+		DiskFull: &ActionDiskFullDescription{
+			Target:   PodTarget{Name: pod.Name(), Namespace: pod.Namespace()},
+			Percent:  fault.Percent,
+			Duration: fault.Duration,
+		},
+	}, nil
+}
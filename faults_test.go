@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFaultCatalogueValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults []FaultConfig
+		err    string
+	}{
+		{
+			name:   "default",
+			faults: DefaultFaultCatalogue().Faults,
+		},
+		{
+			name:   "empty",
+			faults: nil,
+			err:    "faults: at least one enabled fault needs a positive weight",
+		},
+		{
+			name: "all disabled",
+			faults: []FaultConfig{
+				{Kind: FaultKindDrainNode, Weight: 1, Disabled: true},
+				{Kind: FaultKindKillNode, Weight: 0},
+			},
+			err: "faults: at least one enabled fault needs a positive weight",
+		},
+		{
+			name:   "unknown kind",
+			faults: []FaultConfig{{Kind: "Reboot", Weight: 1}},
+			err:    `faults[0].kind: unknown fault kind "Reboot"`,
+		},
+		{
+			name: "negative weight",
+			faults: []FaultConfig{
+				{Kind: FaultKindKillNode, Weight: 1},
+				{Kind: FaultKindDrainNode, Weight: -1},
+			},
+			err: "faults[1].weight: must not be negative",
+		},
+		{
+			name:   "unsupported grace period",
+			faults: []FaultConfig{{Kind: FaultKindKillNode, Weight: 1, GracePeriod: &IntRange{Min: 1, Max: 2}}},
+			err:    "faults[0].gracePeriod: not supported for KillNode",
+		},
+		{
+			name:   "invalid grace period",
+			faults: []FaultConfig{{Kind: FaultKindDrainNode, Weight: 1, GracePeriod: &IntRange{Min: 5, Max: 2}}},
+			err:    "faults[0].gracePeriod.max: must not be less than min",
+		},
+		{
+			name:   "unknown group",
+			faults: []FaultConfig{{Kind: FaultKindDeletePod, Weight: 1, Groups: []PodGroup{"Agents"}}},
+			err:    `faults[0].groups[0]: unknown pod group "Agents"`,
+		},
+		{
+			name:   "zone outage without outage",
+			faults: []FaultConfig{{Kind: FaultKindZoneOutage, Weight: 1, Mode: ZoneOutageKill}},
+			err:    "faults[0].outage: must be positive",
+		},
+		{
+			name:   "partition without duration",
+			faults: []FaultConfig{{Kind: FaultKindPartition, Weight: 1}},
+			err:    "faults[0].duration: required",
+		},
+		{
+			name:   "duration not supported",
+			faults: []FaultConfig{{Kind: FaultKindDeletePod, Weight: 1, Duration: Duration(time.Minute)}},
+			err:    "faults[0].duration: not supported for DeletePod",
+		},
+		{
+			name:   "disk full on coordinators",
+			faults: []FaultConfig{{Kind: FaultKindDiskFull, Weight: 1, Percent: 90, Duration: Duration(time.Minute), Groups: []PodGroup{PodGroupCoordinator}}},
+			err:    "faults[0].groups[0]: Coordinator has no data volume",
+		},
+		{
+			name:   "percent not supported",
+			faults: []FaultConfig{{Kind: FaultKindDeletePod, Weight: 1, Percent: 50}},
+			err:    "faults[0].percent: not supported for DeletePod",
+		},
+		{
+			name: "valid mix",
+			faults: []FaultConfig{
+				{Kind: FaultKindDeletePod, Weight: 3, Groups: []PodGroup{PodGroupDBServer}},
+				{Kind: FaultKindZoneOutage, Weight: 1, Mode: ZoneOutageDrain, Outage: Duration(10 * time.Minute)},
+				{Kind: FaultKindDiskFull, Weight: 1, Percent: 100, Duration: Duration(time.Minute)},
+				{Kind: FaultKindKillNode, Weight: 5, Disabled: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			catalogue := FaultCatalogue{Faults: test.faults}
+			expectError(t, catalogue.Validate(), test.err)
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// BlastRadiusLimits are the maximum numbers of members per server group
+// of a deployment that may be down at the same time. Negative limits are
+// derived from the size of the group.
+type BlastRadiusLimits struct {
+	// MaxAgents defaults to floor((agents-1)/2), keeping the agency quorum
+	MaxAgents int
+	// MaxDBServers defaults to ReplicationFactor-1, keeping one replica
+	// of every shard
+	MaxDBServers int
+	// MaxCoordinators defaults to coordinators-1
+	MaxCoordinators int
+	// ReplicationFactor is the smallest replication factor of the
+	// collections of the deployments
+	ReplicationFactor int
+}
+
+// limit returns the maximum number of members of the group that may be down
+func (l BlastRadiusLimits) limit(group PodGroup, count int) int {
+	switch group {
+	case PodGroupAgent:
+		if l.MaxAgents >= 0 {
+			return l.MaxAgents
+		}
+		return (count - 1) / 2
+	case PodGroupDBServer:
+		if l.MaxDBServers >= 0 {
+			return l.MaxDBServers
+		}
+		if l.ReplicationFactor-1 < count-1 {
+			return l.ReplicationFactor - 1
+		}
+		return count - 1
+	case PodGroupCoordinator:
+		if l.MaxCoordinators >= 0 {
+			return l.MaxCoordinators
+		}
+		return count - 1
+	}
+	return count
+}
+
+// BlastRadiusGuard refuses faults that would take down more members of a
+// server group than allowed by the limits. Members are counted as down if
+// they are not ready, or affected by an admitted fault that has not been
+// released yet.
+type BlastRadiusGuard struct {
+	client     k8s.Interface
+	arango     arangoclient.DatabaseV1alphaInterface
+	namespaces []string
+	limits     BlastRadiusLimits
+
+	mutex    sync.Mutex
+	affected map[string]bool
+}
+
+// NewBlastRadiusGuard creates a guard for the deployments of the namespaces
+func NewBlastRadiusGuard(client k8s.Interface, arango arangoclient.DatabaseV1alphaInterface, namespaces []string, limits BlastRadiusLimits) *BlastRadiusGuard {
+	return &BlastRadiusGuard{
+		client:     client,
+		arango:     arango,
+		namespaces: namespaces,
+		limits:     limits,
+		affected:   make(map[string]bool),
+	}
+}
+
+// memberKey identifies a member across deployments and namespaces
+func memberKey(namespace, deployment string, member arangoapi.MemberStatus) string {
+	return namespace + "/" + deployment + "/" + member.ID
+}
+
+// memberPredicate matches members of the given namespace
+type memberPredicate func(namespace string, member arangoapi.MemberStatus) bool
+
+// Admit checks whether the members affected by the action may go down in
+// addition to those already down. Admitted members count as down until
+// Release is called.
+func (g *BlastRadiusGuard) Admit(ctx context.Context, desc ActionDescription) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	affects, err := g.affects(desc)
+	if err != nil {
+		return err
+	}
+
+	affected := make(map[string]bool)
+	for _, namespace := range g.namespaces {
+		deployments, err := g.arango.ArangoDeployments(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to list deployments")
+		}
+
+		for _, deployment := range deployments.Items {
+			if err := g.checkDeployment(namespace, &deployment, affects, affected); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key := range affected {
+		g.affected[key] = true
+	}
+	return nil
+}
+
+// checkDeployment counts the members of each server group of the deployment
+// that would be down and adds the affected members to affected
+func (g *BlastRadiusGuard) checkDeployment(namespace string, deployment *arangoapi.ArangoDeployment, affects memberPredicate, affected map[string]bool) error {
+	for _, group := range []PodGroup{PodGroupAgent, PodGroupDBServer, PodGroupCoordinator} {
+		members := deploymentGroupMembers(deployment, group)
+
+		down, hit := 0, 0
+		for _, member := range members {
+			key := memberKey(namespace, deployment.GetName(), member)
+			switch {
+			case affects(namespace, member):
+				affected[key] = true
+				hit++
+				down++
+			case g.affected[key] || !member.Conditions.IsTrue(arangoapi.ConditionTypeReady):
+				down++
+			}
+		}
+
+		if hit == 0 {
+			continue
+		}
+		if limit := g.limits.limit(group, len(members)); down > limit {
+			return fmt.Errorf("%d of %d %s members of %s/%s would be down, limit is %d",
+				down, len(members), group, namespace, deployment.GetName(), limit)
+		}
+	}
+	return nil
+}
+
+// Release forgets all admitted faults, once the cluster is healthy again
+func (g *BlastRadiusGuard) Release() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.affected = make(map[string]bool)
+}
+
+// affects returns a predicate matching the members affected by the action.
+// Only the faults of the random chaos are known, other actions are refused
+// as their blast radius can not be checked.
+func (g *BlastRadiusGuard) affects(desc ActionDescription) (memberPredicate, error) {
+	switch desc.Type {
+	case ActionTypeDeletePod:
+		return g.affectsPod(desc.DeletePod.Target)
+
+	case ActionTypePartition:
+		// Only the isolated member counts as down, its peers keep quorum
+		return g.affectsPod(desc.Partition.Target)
+
+	case ActionTypeNetem:
+		return g.affectsPod(desc.Netem.Target)
+
+	case ActionTypeFreeze:
+		return g.affectsPod(desc.Freeze.Target)
+
+	case ActionTypeKillProcess:
+		return g.affectsPod(desc.KillProcess.Target)
+
+	case ActionTypeDiskFull:
+		return g.affectsPod(desc.DiskFull.Target)
+
+	case ActionTypeDrainNode:
+		return g.affectsNode(desc.DrainNode.Target)
+
+	case ActionKillNode:
+		return g.affectsNode(desc.KillNode.Target)
+
+	case ActionTypeZoneOutage:
+		if len(desc.ZoneOutage.Nodes) == 0 {
+			return nil, errors.New("zone nodes are not resolved")
+		}
+		return g.affectsNodes(desc.ZoneOutage.Nodes...)
+	}
+
+	return nil, errors.Errorf("blast radius of %s is unknown", desc.Type)
+}
+
+// affectsPod returns a predicate matching the member of the pod
+func (g *BlastRadiusGuard) affectsPod(target PodTarget) (memberPredicate, error) {
+	if target.Name == "" {
+		return nil, errors.New("pod target is not resolved")
+	}
+	return func(namespace string, m arangoapi.MemberStatus) bool {
+		return m.PodName == target.Name && (target.Namespace == "" || target.Namespace == namespace)
+	}, nil
+}
+
+// affectsNode returns a predicate matching the members with a pod on the node
+func (g *BlastRadiusGuard) affectsNode(target NodeTarget) (memberPredicate, error) {
+	if target.Name == "" {
+		return nil, errors.New("node target is not resolved")
+	}
+
+	return g.affectsNodes(target.Name)
+}
+
+// affectsNodes returns a predicate matching the members with a pod on one of the nodes
+func (g *BlastRadiusGuard) affectsNodes(nodes ...string) (memberPredicate, error) {
+	onNode := make(map[string]bool)
+	for _, node := range nodes {
+		pods, err := getNodePods(g.client, node)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pods {
+			onNode[p.GetNamespace()+"/"+p.GetName()] = true
+		}
+	}
+	return func(namespace string, m arangoapi.MemberStatus) bool {
+		return onNode[namespace+"/"+m.PodName]
+	}, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBlastRadiusLimitsLimit(t *testing.T) {
+	derived := BlastRadiusLimits{MaxAgents: -1, MaxDBServers: -1, MaxCoordinators: -1, ReplicationFactor: 2}
+
+	tests := []struct {
+		limits BlastRadiusLimits
+		group  PodGroup
+		count  int
+		limit  int
+	}{
+		{limits: derived, group: PodGroupAgent, count: 1, limit: 0},
+		{limits: derived, group: PodGroupAgent, count: 3, limit: 1},
+		{limits: derived, group: PodGroupAgent, count: 4, limit: 1},
+		{limits: derived, group: PodGroupAgent, count: 5, limit: 2},
+		{limits: derived, group: PodGroupDBServer, count: 3, limit: 1},
+		{limits: derived, group: PodGroupDBServer, count: 1, limit: 0},
+		{limits: BlastRadiusLimits{MaxDBServers: -1, ReplicationFactor: 3}, group: PodGroupDBServer, count: 5, limit: 2},
+		{limits: BlastRadiusLimits{MaxDBServers: -1, ReplicationFactor: 3}, group: PodGroupDBServer, count: 2, limit: 1},
+		{limits: derived, group: PodGroupCoordinator, count: 3, limit: 2},
+		{limits: BlastRadiusLimits{MaxAgents: 0}, group: PodGroupAgent, count: 5, limit: 0},
+		{limits: BlastRadiusLimits{MaxAgents: 3}, group: PodGroupAgent, count: 3, limit: 3},
+		{limits: BlastRadiusLimits{MaxDBServers: 1, ReplicationFactor: 3}, group: PodGroupDBServer, count: 5, limit: 1},
+		{limits: BlastRadiusLimits{MaxCoordinators: 1}, group: PodGroupCoordinator, count: 3, limit: 1},
+		{limits: derived, group: PodGroupOperator, count: 2, limit: 2},
+	}
+
+	for i, test := range tests {
+		name := fmt.Sprintf("%d/%s/%d", i, test.group, test.count)
+		t.Run(name, func(t *testing.T) {
+			if limit := test.limits.limit(test.group, test.count); limit != test.limit {
+				t.Errorf("expected limit %d, got %d", test.limit, limit)
+			}
+		})
+	}
+}
+
+// testMembers returns count ready members with the given ID prefix, except
+// those listed as not ready
+func testMembers(prefix string, count int, notReady ...int) arangoapi.MemberStatusList {
+	var members arangoapi.MemberStatusList
+	for i := 0; i < count; i++ {
+		ready := v1.ConditionTrue
+		for _, n := range notReady {
+			if n == i {
+				ready = v1.ConditionFalse
+			}
+		}
+		id := fmt.Sprintf("%s-%d", prefix, i)
+		members = append(members, arangoapi.MemberStatus{
+			ID:         id,
+			PodName:    "pod-" + id,
+			Conditions: arangoapi.ConditionList{arangoapi.Condition{Type: arangoapi.ConditionTypeReady, Status: ready}},
+		})
+	}
+	return members
+}
+
+// podsPredicate matches the members running one of the pods
+func podsPredicate(pods ...string) memberPredicate {
+	return func(namespace string, m arangoapi.MemberStatus) bool {
+		for _, pod := range pods {
+			if m.PodName == pod {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestBlastRadiusGuardCheckDeployment(t *testing.T) {
+	limits := BlastRadiusLimits{MaxAgents: -1, MaxDBServers: -1, MaxCoordinators: -1, ReplicationFactor: 2}
+
+	tests := []struct {
+		name     string
+		members  arangoapi.DeploymentStatusMembers
+		admitted []string
+		affects  memberPredicate
+		affected []string
+		err      string
+	}{
+		{
+			name:     "single agent",
+			members:  arangoapi.DeploymentStatusMembers{Agents: testMembers("agent", 3)},
+			affects:  podsPredicate("pod-agent-0"),
+			affected: []string{"chaos/db/agent-0"},
+		},
+		{
+			name:     "agent already admitted",
+			members:  arangoapi.DeploymentStatusMembers{Agents: testMembers("agent", 3)},
+			admitted: []string{"chaos/db/agent-1"},
+			affects:  podsPredicate("pod-agent-0"),
+			err:      "2 of 3 Agent members of chaos/db would be down, limit is 1",
+		},
+		{
+			name:    "agent not ready",
+			members: arangoapi.DeploymentStatusMembers{Agents: testMembers("agent", 3, 2)},
+			affects: podsPredicate("pod-agent-0"),
+			err:     "2 of 3 Agent members of chaos/db would be down, limit is 1",
+		},
+		{
+			name: "other group not ready",
+			members: arangoapi.DeploymentStatusMembers{
+				DBServers:    testMembers("dbserver", 3, 0, 1),
+				Coordinators: testMembers("coordinator", 2),
+			},
+			affects:  podsPredicate("pod-coordinator-0"),
+			affected: []string{"chaos/db/coordinator-0"},
+		},
+		{
+			name:    "two dbservers on a node",
+			members: arangoapi.DeploymentStatusMembers{DBServers: testMembers("dbserver", 3)},
+			affects: podsPredicate("pod-dbserver-0", "pod-dbserver-2"),
+			err:     "2 of 3 DBServer members of chaos/db would be down, limit is 1",
+		},
+		{
+			name:    "no member",
+			members: arangoapi.DeploymentStatusMembers{Agents: testMembers("agent", 3, 0, 1, 2)},
+			affects: podsPredicate("pod-other"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			guard := &BlastRadiusGuard{limits: limits, affected: make(map[string]bool)}
+			for _, key := range test.admitted {
+				guard.affected[key] = true
+			}
+			deployment := &arangoapi.ArangoDeployment{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+			deployment.Status.Members = test.members
+
+			affected := make(map[string]bool)
+			expectError(t, guard.checkDeployment("chaos", deployment, test.affects, affected), test.err)
+
+			if len(affected) != len(test.affected) {
+				t.Fatalf("expected affected members %v, got %v", test.affected, affected)
+			}
+			for _, key := range test.affected {
+				if !affected[key] {
+					t.Errorf("member %s not affected", key)
+				}
+			}
+		})
+	}
+}
+
+func TestBlastRadiusGuardAffects(t *testing.T) {
+	guard := &BlastRadiusGuard{}
+
+	tests := []struct {
+		name string
+		desc ActionDescription
+		err  string
+	}{
+		{
+			name: "pod",
+			desc: ActionDescription{Type: ActionTypeDeletePod, DeletePod: &ActionDeletePodDescription{Target: PodTarget{Name: "pod-agent-0"}}},
+		},
+		{
+			name: "unresolved pod",
+			desc: ActionDescription{Type: ActionTypeFreeze, Freeze: &ActionFreezeDescription{Target: PodTarget{Group: PodGroupAgent}}},
+			err:  "pod target is not resolved",
+		},
+		{
+			name: "unresolved zone",
+			desc: ActionDescription{Type: ActionTypeZoneOutage, ZoneOutage: &ActionZoneOutageDescription{}},
+			err:  "zone nodes are not resolved",
+		},
+		{
+			name: "eviction",
+			desc: ActionDescription{Type: ActionTypeEvictPod, EvictPod: &ActionEvictPodDescription{Target: PodTarget{Name: "pod-agent-0"}}},
+			err:  "blast radius of EvictPod is unknown",
+		},
+		{
+			name: "pvc deletion",
+			desc: ActionDescription{Type: ActionDeletePVC, DeletePVC: &ActionDeletePVCDescription{Target: PodTarget{Name: "pod-agent-0"}}},
+			err:  "blast radius of DeletePVC is unknown",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			affects, err := guard.affects(test.desc)
+			expectError(t, err, test.err)
+			if err != nil {
+				return
+			}
+			if !affects("chaos", arangoapi.MemberStatus{PodName: "pod-agent-0"}) {
+				t.Errorf("member of the pod not affected")
+			}
+			if affects("chaos", arangoapi.MemberStatus{PodName: "pod-agent-1"}) {
+				t.Errorf("member of another pod affected")
+			}
+		})
+	}
+}
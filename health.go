@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+
+	driver "github.com/arangodb/go-driver"
+	"github.com/arangodb/go-driver/http"
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	jg "github.com/dgrijalva/jwt-go"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// healthChecker checks the readiness of the ArangoDB deployments of a set of namespaces
+type healthChecker struct {
+	client     k8s.Interface
+	arango     arangoclient.DatabaseV1alphaInterface
+	namespaces []string
+	selector   TargetSelector
+}
+
+// newHealthChecker creates a health checker for the deployments of the
+// namespaces allowed by the selector
+func newHealthChecker(client k8s.Interface, arango arangoclient.DatabaseV1alphaInterface, namespaces []string, selector TargetSelector) *healthChecker {
+	return &healthChecker{
+		client:     client,
+		arango:     arango,
+		namespaces: namespaces,
+		selector:   selector,
+	}
+}
+
+// generateJWTForDeployment creates a superuser token using the JWT secret of the deployment
+func generateJWTForDeployment(client k8s.Interface, namespace string, deployment *arangoapi.ArangoDeployment) (string, error) {
+	secret, err := k8sutil.GetTokenSecret(client.CoreV1().Secrets(namespace), deployment.Spec.Authentication.GetJWTSecretName())
+	if err != nil {
+		return "", err
+	}
+
+	token := jg.NewWithClaims(jg.SigningMethodHS256, jg.MapClaims{
+		"iss":       "arangodb",
+		"server_id": "CHAOS!!!!!",
+	})
+
+	// Sign and get the complete encoded token as a string using the secret
+	signedToken, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", driver.WithStack(err)
+	}
+
+	return signedToken, nil
+}
+
+// externalService returns the external access LoadBalancer of the deployment
+func (h *healthChecker) externalService(namespace, deploymentName string) (*v1.Service, error) {
+	srv, err := h.client.CoreV1().Services(namespace).Get(deploymentName+"-ea", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if srv.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return nil, fmt.Errorf("No external access to arangodb deployment %s", deploymentName)
+	}
+
+	return srv, nil
+}
+
+// newDatabaseClient creates an authenticated client using the external access of the deployment.
+// Returns nil without error if the LoadBalancer has no IP yet.
+func (h *healthChecker) newDatabaseClient(ctx context.Context, namespace, deploymentName string) (driver.Client, error) {
+	deployment, err := h.arango.ArangoDeployments(namespace).Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	srv, err := h.externalService(namespace, deploymentName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(srv.Status.LoadBalancer.Ingress) == 0 {
+		log.Println("No LoadBalancer IP known for " + deploymentName)
+		return nil, nil
+	}
+
+	hasTLS := deployment.Spec.TLS.GetCASecretName() != "None"
+
+	var config http.ConnectionConfig
+	if hasTLS {
+		config.Endpoints = []string{"https://" + srv.Status.LoadBalancer.Ingress[0].IP + ":8529"}
+		config.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	} else {
+		config.Endpoints = []string{"http://" + srv.Status.LoadBalancer.Ingress[0].IP + ":8529"}
+	}
+
+	config.DontFollowRedirect = true
+
+	conn, err := http.NewConnection(config)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateJWTForDeployment(h.client, namespace, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.NewClient(driver.ClientConfig{
+		Connection:     conn,
+		Authentication: driver.RawAuthentication("bearer " + token),
+	})
+}
+
+// checkDeploymentInSync checks that all servers are healthy and all collections are in sync
+func (h *healthChecker) checkDeploymentInSync(ctx context.Context, namespace, deploymentName string) error {
+	dbc, err := h.newDatabaseClient(ctx, namespace, deploymentName)
+	if err != nil {
+		return err
+	} else if dbc == nil {
+		return nil
+	}
+
+	cluster, err := dbc.Cluster(ctx)
+	if err != nil {
+		return err
+	}
+
+	health, err := cluster.Health(ctx)
+	if err != nil {
+		return err
+	}
+
+	for name, m := range health.Health {
+		if m.CanBeDeleted {
+			continue // Ignore servers that can be deleted
+		}
+		if m.Status != driver.ServerStatusGood {
+			return fmt.Errorf("Member Status not GOOD: %s/%s", deploymentName, name)
+		}
+	}
+
+	databases, err := dbc.Databases(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, db := range databases {
+		inventory, err := cluster.DatabaseInventory(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		for _, coll := range inventory.Collections {
+			if !coll.AllInSync {
+				return fmt.Errorf("Collection not ready: %s", coll.Parameters.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkMembersReady checks that all members of the deployment exist and that they and their pods are ready
+func checkMembersReady(client k8s.Interface, namespace string, deployment *arangoapi.ArangoDeployment) error {
+	if len(deployment.Status.Members.Agents) != deployment.Spec.Agents.GetCount() {
+		return fmt.Errorf("Missing agents: %s", deployment.GetName())
+	}
+	if len(deployment.Status.Members.DBServers) != deployment.Spec.DBServers.GetCount() {
+		return fmt.Errorf("Missing dbservers: %s", deployment.GetName())
+	}
+	if len(deployment.Status.Members.Coordinators) != deployment.Spec.Coordinators.GetCount() {
+		return fmt.Errorf("Missing coordinators: %s", deployment.GetName())
+	}
+
+	if deployment.Status.Phase != arangoapi.DeploymentPhaseRunning {
+		log.Printf("Deployment is not running: %s", deployment.GetName())
+		return fmt.Errorf("Deployment is not running: %s", deployment.GetName())
+	}
+
+	return deployment.Status.Members.ForeachServerGroup(func(group arangoapi.ServerGroup, members arangoapi.MemberStatusList) error {
+		for _, member := range members {
+			if !member.Conditions.IsTrue(arangoapi.ConditionTypeReady) {
+				log.Printf("Member not ready: %s/%s", deployment.GetName(), member.ID)
+				return fmt.Errorf("Member not ready: %s", member.ID)
+			}
+
+			// Check if the pod exists and is in ready state
+			pod, err := client.CoreV1().Pods(namespace).Get(member.PodName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			if !isPodReady(pod) {
+				return fmt.Errorf("Pod not ready: %s", member.PodName)
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkDeploymentReady checks that all members of the deployment exist, are ready and in sync
+func (h *healthChecker) checkDeploymentReady(ctx context.Context, namespace, deploymentName string) error {
+	deployment, err := h.arango.ArangoDeployments(namespace).Get(deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := checkMembersReady(h.client, namespace, deployment); err != nil {
+		return err
+	}
+
+	if err := h.checkDeploymentInSync(ctx, namespace, deployment.GetName()); err != nil {
+		return err
+	}
+	log.Printf("Deployment ready: %s/%s", namespace, deployment.GetName())
+	return nil
+}
+
+// WaitForDeploymentReady waits until the given deployment is ready
+func (h *healthChecker) WaitForDeploymentReady(ctx context.Context, namespace, deploymentName string) error {
+	return retry(ctx, func() error {
+		return h.checkDeploymentReady(ctx, namespace, deploymentName)
+	})
+}
+
+// WaitForDeploymentsReady waits until all selected deployments of all namespaces are ready
+func (h *healthChecker) WaitForDeploymentsReady(ctx context.Context) error {
+	for _, namespace := range h.namespaces {
+		deployments, err := h.arango.ArangoDeployments(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		for _, deployment := range deployments.Items {
+			if !h.selector.AllowsDeployment(deployment.GetName()) {
+				continue
+			}
+			if err := h.WaitForDeploymentReady(ctx, namespace, deployment.GetName()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// helperPrefix is the name prefix of the helper pods
+	helperPrefix = "chaos-"
+	// helperMargin is added to the deadline of a helper for its pod, so
+	// the helper can revert the fault before the pod is killed
+	helperMargin = time.Minute
+	// defaultHelperImage is used by helpers only needing a shell
+	defaultHelperImage = "busybox"
+)
+
+// helperOptions describe a privileged helper pod in the host PID namespace
+// of the node of a target pod. Apply and Revert are shell commands, which
+// find the first process of the arangod container in $pid and all of them
+// in $pids.
+type helperOptions struct {
+	// Kind names the fault, a pod can have one helper per kind
+	Kind     string
+	Image    string
+	Apply    string
+	Revert   string
+	Deadline time.Duration
+}
+
+// helperScript applies the fault to the processes of the container and
+// reverts it on termination or after the deadline
+const helperScript = `
+pids=""
+for p in /proc/[0-9]*; do
+  if grep -q "$CONTAINER_ID" "$p/cgroup" 2>/dev/null; then pids="$pids ${p#/proc/}"; fi
+done
+set -- $pids
+if [ $# -eq 0 ]; then echo "no process of container $CONTAINER_ID found" >&2; exit 1; fi
+pid=$1
+eval "$APPLY" || exit 1
+trap 'eval "$REVERT"; exit 0' TERM INT
+touch /tmp/ready
+end=$(( $(date +%s) + DEADLINE ))
+while [ "$(date +%s)" -lt "$end" ]; do sleep 1; done
+eval "$REVERT"
+`
+
+// helperName returns the name of the helper of the given kind for the pod
+func helperName(kind, name string) string {
+	return helperPrefix + kind + "-" + name
+}
+
+// containerID returns the runtime ID of the container of the pod
+func containerID(pod *v1.Pod, container string) (string, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != container {
+			continue
+		}
+		if i := strings.Index(status.ContainerID, "://"); i >= 0 {
+			return status.ContainerID[i+3:], nil
+		}
+		break
+	}
+	return "", errors.Errorf("container %s of pod %s is not running", container, pod.GetName())
+}
+
+// startHelper creates a helper pod on the node of the target pod and
+// returns once the fault is applied
+func startHelper(ctx context.Context, client k8s.Interface, namespace, name string, options helperOptions) error {
+	target, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get pod")
+	}
+	if target.Spec.NodeName == "" {
+		return fmt.Errorf("pod %s is not scheduled", name)
+	}
+
+	id, err := containerID(target, k8sutil.ServerContainerName)
+	if err != nil {
+		return err
+	}
+
+	privileged := true
+	deadline := int64((options.Deadline + helperMargin) / time.Second)
+	helper := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   helperName(options.Kind, name),
+			Labels: map[string]string{"app": "arangodb-chaos-" + options.Kind},
+		},
+		Spec: v1.PodSpec{
+			NodeName:              target.Spec.NodeName,
+			HostPID:               true,
+			RestartPolicy:         v1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Tolerations:           []v1.Toleration{{Operator: v1.TolerationOpExists}},
+			Containers: []v1.Container{
+				{
+					Name:    options.Kind,
+					Image:   options.Image,
+					Command: []string{"sh", "-c", helperScript},
+					Env: []v1.EnvVar{
+						{Name: "CONTAINER_ID", Value: id},
+						{Name: "APPLY", Value: options.Apply},
+						{Name: "REVERT", Value: options.Revert},
+						{Name: "DEADLINE", Value: strconv.FormatInt(int64(options.Deadline/time.Second), 10)},
+					},
+					SecurityContext: &v1.SecurityContext{Privileged: &privileged},
+					ReadinessProbe: &v1.Probe{
+						Handler: v1.Handler{
+							Exec: &v1.ExecAction{Command: []string{"cat", "/tmp/ready"}},
+						},
+						PeriodSeconds: 1,
+					},
+				},
+			},
+		},
+	}
+
+	if _, err := client.CoreV1().Pods(namespace).Create(helper); err != nil {
+		return errors.Wrapf(err, "failed to create %s helper", options.Kind)
+	}
+
+	if err := waitForHelper(ctx, client, namespace, helper.GetName()); err != nil {
+		if err := stopHelper(context.Background(), client, namespace, name, options.Kind); err != nil {
+			log.Printf("Failed to delete helper %s: %s", helper.GetName(), err.Error())
+		}
+		return err
+	}
+
+	log.Printf("Helper %s applied %s to pod %s/%s", helper.GetName(), options.Kind, namespace, name)
+	return nil
+}
+
+// waitForHelper waits until the helper reports the fault as applied
+func waitForHelper(ctx context.Context, client k8s.Interface, namespace, name string) error {
+	for {
+		obj, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to get helper")
+		}
+
+		switch {
+		case obj.Status.Phase == v1.PodFailed || obj.Status.Phase == v1.PodSucceeded:
+			return errors.Errorf("helper %s terminated before applying the fault", name)
+		case isPodReady(obj):
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// stopHelper deletes the helper of the given kind of the target pod, which
+// reverts the fault on termination. Ignores if there is no helper.
+func stopHelper(ctx context.Context, client k8s.Interface, namespace, name, kind string) error {
+	err := deletePod(ctx, client, namespace, helperName(kind, name), &metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
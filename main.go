@@ -2,21 +2,19 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
-	driver "github.com/arangodb/go-driver"
-	"github.com/arangodb/go-driver/http"
-	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
 	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
-	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
-	jg "github.com/dgrijalva/jwt-go"
-	v1 "k8s.io/api/core/v1"
+	"github.com/pkg/errors"
+	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8s "k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -40,24 +38,130 @@ func retry(ctx context.Context, predicate func() error) error {
 }
 
 var (
-	namespace    string
-	disableChaos bool
-	concurrent   int
+	namespace         string
+	namespaceSelector string
+	disableChaos      bool
+	concurrent        int
+	scriptPath        string
+	healthTimeout     time.Duration
+	dryRun            bool
+	dryRunRounds      int
+	seed              int64
+	faultsPath        string
+	schedule          ChaosSchedule
+	scheduleSpec      string
+	activeWindows     string
+	limits            BlastRadiusLimits
+	podSelector       string
+	nodeSelector      string
+	deploymentsList   string
+	excludeList       string
+	replayPath        string
+	nodeRefresh       time.Duration
+
+	nodeTerminator        string
+	nodeTerminatorOptions NodeTerminatorOptions
 )
 
 func init() {
-	flag.StringVar(&namespace, "namespace", "default", "Namespace to use, must exist")
+	flag.StringVar(&namespace, "namespace", "default", "Comma separated namespaces to use, must exist")
+	flag.StringVar(&namespaceSelector, "namespace-selector", "", "Label selector of namespaces to use in addition to -namespace")
 	flag.BoolVar(&disableChaos, "disable-chaos", false, "Use to disable chaos and only create logs")
 	flag.IntVar(&concurrent, "concurrent-chaos", 1, "Amount of concurrent chaos")
+	flag.StringVar(&scriptPath, "script", "", "Run the given action script (json or yaml) instead of random chaos")
+	flag.DurationVar(&healthTimeout, "health-timeout", 10*time.Minute, "Maximum time a script action or a health check between random faults waits for cluster health, zero waits forever")
+	flag.BoolVar(&dryRun, "dry-run", false, "Only print the planned chaos, without changing the cluster")
+	flag.IntVar(&dryRunRounds, "dry-run-rounds", 10, "Number of random chaos rounds to plan in dry-run mode")
+	flag.Int64Var(&seed, "seed", 0, "Seed of the random chaos, defaults to the current time")
+	flag.StringVar(&replayPath, "replay-file", "", "File the random chaos is recorded to as action script, defaults to logs/<start time>/replay.json")
+	flag.StringVar(&faultsPath, "faults", "", "Fault catalogue (json or yaml) of the random chaos, uses the built-in faults if not set")
+	flag.StringVar(&scheduleSpec, "schedule", "uniform:100s", fmt.Sprintf("Time between faults as <scheduler>:<duration>, schedulers are %v", SchedulerNames()))
+	flag.StringVar(&activeWindows, "active-windows", "", "Only start faults within these windows of local time, e.g. \"Mon-Fri 09:00-17:00; Sat 10:00-12:00\"")
+	flag.DurationVar(&schedule.Duration, "duration", 0, "Total duration of the random chaos, unlimited if zero")
+	flag.IntVar(&limits.MaxAgents, "max-agents-down", -1, "Maximum number of agents per deployment down at the same time, defaults to keeping the quorum")
+	flag.IntVar(&limits.MaxDBServers, "max-dbservers-down", -1, "Maximum number of dbservers per deployment down at the same time, defaults to replication-factor - 1")
+	flag.IntVar(&limits.MaxCoordinators, "max-coordinators-down", -1, "Maximum number of coordinators per deployment down at the same time, defaults to all but one")
+	flag.IntVar(&limits.ReplicationFactor, "replication-factor", 2, "Smallest replication factor of the collections, limits the number of dbservers down")
+	flag.StringVar(&podSelector, "pod-selector", "", "Label selector restricting the pods chaos may target")
+	flag.StringVar(&nodeSelector, "node-selector", "", "Label selector restricting the nodes chaos may target")
+	flag.StringVar(&deploymentsList, "deployments", "", "Comma separated ArangoDeployments chaos may target, all if empty")
+	flag.StringVar(&excludeList, "exclude", "", "Comma separated names of pods, nodes and deployments chaos never targets")
+	flag.DurationVar(&nodeRefresh, "node-refresh", time.Minute, "Interval the nodes usable by random chaos are refreshed at")
+	flag.StringVar(&nodeTerminator, "node-terminator", "simulate", "Provider used to kill nodes")
+	flag.BoolVar(&nodeTerminatorOptions.DeleteNode, "simulate-delete-node", false, "Delete the Node object when simulating a node crash")
 }
 
-type cleanupFunc func() error
+// resolveNamespaces returns the comma separated namespaces followed by
+// those matching the label selector
+func resolveNamespaces(client k8s.Interface, list, selector string) ([]string, error) {
+	namespaces := splitNames(list)
+
+	if selector != "" {
+		matching, err := client.CoreV1().Namespaces().List(metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list namespaces")
+		}
+
+		for _, ns := range matching.Items {
+			if !containsString(namespaces, ns.GetName()) {
+				namespaces = append(namespaces, ns.GetName())
+			}
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return nil, errors.New("no namespace selected")
+	}
+
+	for _, ns := range namespaces {
+		log.Printf("Using namespace %s", ns)
+	}
+	return namespaces, nil
+}
+
+// maxGuardAttempts is the number of faults picked before giving up if the
+// blast radius guard rejects all of them
+const maxGuardAttempts = 10
+
+// pendingCleanup is the cleanup of a random fault, registered with the
+// cleanup registry so it also runs if the agent fails
+type pendingCleanup struct {
+	desc ActionDescription
+	run  cleanupFunc
+}
 
 func main() {
 
 	flag.Parse()
 
-	rand.Seed(time.Now().Unix())
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rand.Seed(seed)
+
+	scheduler, err := NewScheduler(scheduleSpec)
+	if err != nil {
+		log.Fatalf("Failed to create scheduler: %s", err.Error())
+	}
+	schedule.Scheduler = scheduler
+
+	schedule.Windows, err = ParseActiveWindows(activeWindows)
+	if err != nil {
+		log.Fatalf("Failed to parse active windows: %s", err.Error())
+	}
+
+	selector, err := NewTargetSelector(podSelector, nodeSelector, deploymentsList, excludeList)
+	if err != nil {
+		log.Fatalf("Failed to create target selector: %s", err.Error())
+	}
+
+	faults := DefaultFaultCatalogue()
+	if faultsPath != "" {
+		faults, err = LoadFaultCatalogue(faultsPath)
+		if err != nil {
+			log.Fatalf("Failed to load fault catalogue: %s", err.Error())
+		}
+	}
 
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 		clientcmd.NewDefaultClientConfigLoadingRules(),
@@ -74,269 +178,109 @@ func main() {
 	}
 
 	startTime := time.Now().UTC().Format(time.RFC3339)
-	log.Printf("Starting k8s chaos agent, %s", startTime)
+	log.Printf("Starting k8s chaos agent, %s, seed %d", startTime, seed)
 
-	/*api, err := apiextension.NewForConfig(config)
-	if err != nil {
-		panic(err)
-	}*/
-
-	arango, err := arangoclient.NewForConfig(config)
+	api, err := apiextension.NewForConfig(config)
 	if err != nil {
 		panic(err)
 	}
 
-	deployments, err := arango.ArangoDeployments(namespace).List(metav1.ListOptions{})
+	arango, err := arangoclient.NewForConfig(config)
 	if err != nil {
 		panic(err)
 	}
 
-	services, err := client.CoreV1().Services(namespace).List(metav1.ListOptions{})
+	namespaces, err := resolveNamespaces(client, namespace, namespaceSelector)
 	if err != nil {
-		panic(err)
-	}
-
-	deploymentExternalServiceMap := make(map[string]v1.Service)
-
-	for _, deployment := range deployments.Items {
-		log.Printf("Found ArangoDB deployment %s", deployment.GetName())
-		for _, service := range services.Items {
-			if service.GetName() == deployment.GetName()+"-ea" {
-				if service.Spec.Type == v1.ServiceTypeLoadBalancer {
-					log.Println("Found external access LoadBalancer")
-					deploymentExternalServiceMap[deployment.GetName()] = service
-				}
-			}
-		}
+		log.Fatalf("Failed to resolve namespaces: %s", err.Error())
 	}
 
-	generateJWTForDeployment := func(ctx context.Context, deploymentName string) (string, error) {
-		deployment, err := arango.ArangoDeployments(namespace).Get(deploymentName, metav1.GetOptions{})
+	for _, ns := range namespaces {
+		deployments, err := arango.ArangoDeployments(ns).List(metav1.ListOptions{})
 		if err != nil {
-			return "", err
-		}
-
-		secret, err := k8sutil.GetTokenSecret(client.CoreV1().Secrets(namespace), deployment.Spec.Authentication.GetJWTSecretName())
-		if err != nil {
-			return "", err
+			panic(err)
 		}
 
-		token := jg.NewWithClaims(jg.SigningMethodHS256, jg.MapClaims{
-			"iss":       "arangodb",
-			"server_id": "CHAOS!!!!!",
-		})
-
-		// Sign and get the complete encoded token as a string using the secret
-		signedToken, err := token.SignedString([]byte(secret))
-		if err != nil {
-			return "", driver.WithStack(err)
+		for _, deployment := range deployments.Items {
+			log.Printf("Found ArangoDB deployment %s/%s", ns, deployment.GetName())
 		}
-
-		return signedToken, nil
 	}
 
-	waitForDeploymentInSync := func(ctx context.Context, deploymentName string) error {
-		deployment, err := arango.ArangoDeployments(namespace).Get(deploymentName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-
-		srv, ok := deploymentExternalServiceMap[deploymentName]
-		if !ok {
-			return fmt.Errorf("No external access to arangodb deployment %s", deploymentName)
-		}
-
-		if len(srv.Status.LoadBalancer.Ingress) == 0 {
-			log.Println("No LoadBalancer IP known for " + deploymentName)
-			return nil
-		}
-
-		hasTLS := deployment.Spec.TLS.GetCASecretName() != "None"
-
-		var config http.ConnectionConfig
-		if hasTLS {
-			config.Endpoints = []string{"https://" + srv.Status.LoadBalancer.Ingress[0].IP + ":8529"}
-			config.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-		} else {
-			config.Endpoints = []string{"http://" + srv.Status.LoadBalancer.Ingress[0].IP + ":8529"}
-		}
-
-		config.DontFollowRedirect = true
-
-		conn, err := http.NewConnection(config)
-		if err != nil {
-			return err
-		}
-
-		token, err := generateJWTForDeployment(ctx, deploymentName)
-		if err != nil {
-			return err
-		}
-
-		dbc, err := driver.NewClient(driver.ClientConfig{
-			Connection:     conn,
-			Authentication: driver.RawAuthentication("bearer " + token),
-		})
-		if err != nil {
-			return err
-		}
+	health := newHealthChecker(client, arango, namespaces, selector)
 
-		cluster, err := dbc.Cluster(ctx)
-		if err != nil {
-			return err
-		}
-
-		health, err := cluster.Health(ctx)
-		if err != nil {
-			return err
-		}
-
-		for name, m := range health.Health {
-			if m.CanBeDeleted {
-				continue // Ignore servers that can be deleted
-			}
-			if m.Status != driver.ServerStatusGood {
-				return fmt.Errorf("Member Status not GOOD: %s/%s", deploymentName, name)
-			}
-		}
+	/*waitForDeploymentsInSync := func() {
 
-		databases, err := dbc.Databases(ctx)
-		if err != nil {
-			return err
-		}
+	}*/
 
-		for _, db := range databases {
-			inventory, err := cluster.DatabaseInventory(ctx, db)
+	/*ctx, cancel := context.WithTimeout(context.Background(), 22*time.Minute)
+	defer cancel()*/
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stop the chaos on the first signal, running actions clean up after
+	// themselves. A second signal terminates immediately.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		log.Printf("Received %s, stopping chaos", sig)
+		cancel()
+	}()
+
+	if !dryRun {
+		for _, ns := range namespaces {
+			_, err = NewPodLogger(ctx, ns, "logs/"+startTime+"/pods", client)
 			if err != nil {
-				return err
-			}
-
-			for _, coll := range inventory.Collections {
-				if !coll.AllInSync {
-					return fmt.Errorf("Collection not ready: %s", coll.Parameters.Name)
-				}
+				log.Fatalf("Failed to create pod logger: %s", err.Error())
 			}
 		}
-
-		return nil
-	}
-
-	waitForDeploymentReady := func(ctx context.Context, deploymentName string) error {
-		return retry(ctx, func() error {
-			deployment, err := arango.ArangoDeployments(namespace).Get(deploymentName, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
-
-			if len(deployment.Status.Members.Agents) != deployment.Spec.Agents.GetCount() {
-				return fmt.Errorf("Missing agents: %s", deployment.GetName())
-			}
-			if len(deployment.Status.Members.DBServers) != deployment.Spec.DBServers.GetCount() {
-				return fmt.Errorf("Missing dbservers: %s", deployment.GetName())
-			}
-			if len(deployment.Status.Members.Coordinators) != deployment.Spec.Coordinators.GetCount() {
-				return fmt.Errorf("Missing coordinators: %s", deployment.GetName())
-			}
-
-			if deployment.Status.Phase != arangoapi.DeploymentPhaseRunning {
-				log.Printf("Deployment is not running: %s", deployment.GetName())
-				return fmt.Errorf("Deployment is not running: %s", deployment.GetName())
-			}
-
-			if err := deployment.Status.Members.ForeachServerGroup(func(group arangoapi.ServerGroup, members arangoapi.MemberStatusList) error {
-				for _, member := range members {
-					if !member.Conditions.IsTrue(arangoapi.ConditionTypeReady) {
-						log.Printf("Member not ready: %s/%s", deployment.GetName(), member.ID)
-						return fmt.Errorf("Member not ready: %s", member.ID)
-					}
-
-					// Check if the pod exists and is in ready state
-					pod, err := client.CoreV1().Pods(namespace).Get(member.PodName, metav1.GetOptions{})
-					if err != nil {
-						return err
-					}
-
-					podReady := false
-					for _, cond := range pod.Status.Conditions {
-						if cond.Type == v1.PodReady && cond.Status == v1.ConditionTrue {
-							podReady = true
-						}
-					}
-
-					if !podReady {
-						return fmt.Errorf("Pod not ready: %s", member.PodName)
-					}
-				}
-
-				return nil
-			}); err != nil {
-				return err
-			}
-
-			if err := waitForDeploymentInSync(ctx, deployment.GetName()); err != nil {
-				return err
-			}
-			log.Printf("Deployment ready: %s", deployment.GetName())
-			return nil
-		})
 	}
 
-	waitForDeploymentsReady := func(ctx context.Context) error {
-		for _, deployment := range deployments.Items {
-			if err := waitForDeploymentReady(ctx, deployment.GetName()); err != nil {
-				return err
-			}
-		}
-
-		return nil
+	terminator, err := NewNodeTerminator(nodeTerminator, client, nodeTerminatorOptions)
+	if err != nil {
+		log.Fatalf("Failed to create node terminator: %s", err.Error())
 	}
 
-	/*waitForDeploymentsInSync := func() {
-
-	}*/
-
-	nodes, err := client.CoreV1().Nodes().List(metav1.ListOptions{})
+	env, err := NewEnvironment(EnvironmentConfig{
+		Config:        config,
+		Client:        client,
+		Arango:        arango,
+		API:           api,
+		Namespaces:    namespaces,
+		Health:        health,
+		Terminator:    terminator,
+		Selector:      selector,
+		HealthTimeout: healthTimeout,
+		NodeRefresh:   nodeRefresh,
+	})
 	if err != nil {
-		log.Fatalf("failed to obtain node list: %s", err.Error())
+		log.Fatalf("Failed to create environment: %s", err.Error())
+	}
+	if dryRun {
+		log.Print("Dry run, no changes are made to the cluster")
+		env = NewDryRunEnvironment(env)
 	}
 
-	var usableNodes []string
-
-	for _, node := range nodes.Items {
-
-		if node.Spec.Unschedulable {
-			log.Printf("Can not use node %s, unschedulable", node.GetName())
-			continue
-		}
-
-		if len(node.Spec.Taints) > 0 {
-			log.Printf("Can not use node %s, tainted", node.GetName())
-			continue
-		}
+	// fatalf reverts the faults still in place before terminating
+	fatalf := func(format string, args ...interface{}) {
+		env.Cleanups().RunAll()
+		log.Fatalf(format, args...)
+	}
 
-		ready := false
-		for _, cond := range node.Status.Conditions {
-			if cond.Type == v1.NodeReady {
-				ready = true
-				break
-			}
+	if scriptPath != "" {
+		script, err := LoadActionScript(scriptPath)
+		if err != nil {
+			log.Fatalf("Failed to load script: %s", err.Error())
 		}
 
-		if !ready {
-			log.Printf("Can not use node %s, not ready", node.GetName())
-			continue
+		log.Printf("Running script %s with %d actions", scriptPath, len(script.Actions))
+		if err := NewExecutor(env).Run(ctx, script.Actions); err != nil {
+			fatalf("Script failed: %s", err.Error())
 		}
 
-		log.Printf("Using node %s", node.GetName())
-		usableNodes = append(usableNodes, node.GetName())
-	}
-
-	/*ctx, cancel := context.WithTimeout(context.Background(), 22*time.Minute)
-	defer cancel()*/
-	ctx := context.Background()
-	_, err = NewPodLogger(ctx, namespace, "logs/"+startTime+"/pods", client)
-	if err != nil {
-		log.Fatalf("Failed to create pod logger: %s", err.Error())
+		log.Printf("Script completed")
+		return
 	}
 
 	if disableChaos {
@@ -349,160 +293,176 @@ func main() {
 		}
 	}
 
-	time.Sleep(10 * time.Second)
+	// A dry run is not recorded, its replay would look like a real run
+	var replay *replayRecorder
+	if !dryRun {
+		if replayPath == "" {
+			replayPath = "logs/" + startTime + "/replay.json"
+		}
+		replay, err = newReplayRecorder(replayPath, seed)
+		if err != nil {
+			log.Fatalf("Failed to create replay file: %s", err.Error())
+		}
+		log.Printf("Recording chaos to %s, replay using -script", replayPath)
+	}
 
-	if err := waitForDeploymentsReady(ctx); err != nil {
-		log.Fatalf("Deployment not ready: %s", err.Error())
+	if err := env.Sleep(ctx, 10*time.Second); err != nil {
+		log.Fatalf("Failed to wait: %s", err.Error())
 	}
-	// Returns a (cleanup, chaos) functions
-	generateChaos := func() (func(), func()) {
-		switch rand.Intn(11) {
-		case 0, 1, 2:
-			return nil, func() {
-				pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{})
-				if err != nil {
-					log.Printf("Failed to get pod list: %s", err.Error())
-				}
 
-				if len(pods.Items) > 0 {
+	// The initial wait is not limited by -health-timeout
+	waitForHealth := env.WaitForHealth
+	if !dryRun {
+		waitForHealth = health.WaitForDeploymentsReady
+	}
+	if err := waitForHealth(ctx); err != nil {
+		log.Fatalf("Deployment not ready: %s", err.Error())
+	}
+	guard := NewBlastRadiusGuard(client, arango, namespaces, limits)
+
+	// generate picks faults until one is admitted by the guard
+	generate := func() (*ActionDescription, *ActionDescription) {
+		for attempt := 0; attempt < maxGuardAttempts; attempt++ {
+			cleanup, chaos := faults.Generate(ctx, env)
+			if chaos == nil {
+				return nil, nil
+			}
 
-					podid := rand.Intn(len(pods.Items))
+			if err := guard.Admit(ctx, *chaos); err != nil {
+				log.Printf("Rejected %s: %s", chaos.Type, err.Error())
+				continue
+			}
+			return cleanup, chaos
+		}
 
-					gracePeriod := int64(0)
+		log.Printf("No fault admitted after %d attempts", maxGuardAttempts)
+		return nil, nil
+	}
 
-					if err := deletePod(ctx, client, namespace, pods.Items[podid].GetName(), &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
-						log.Fatalf("Failed to delete pod: %s", err.Error())
-					}
-				}
+	run := func(ctx context.Context, iface ActionInterface, desc ActionDescription) {
+		if err := NewExecutor(iface).Run(ctx, ActionDescriptionList{desc}); err != nil {
+			if ctx.Err() != nil {
+				log.Printf("Chaos interrupted: %s", err.Error())
+				return
 			}
-
-		case 3, 4:
-			nodeid := rand.Intn(len(usableNodes))
-			return func() {
-					if err := uncordonNode(client, usableNodes[nodeid]); err != nil {
-						log.Fatalf("Failed to uncordon node: %s", err.Error())
-					}
-				}, func() {
-
-					log.Printf("Draining node %s", usableNodes[nodeid])
-					if err := drainNode(ctx, client, usableNodes[nodeid], &metav1.DeleteOptions{}); err != nil {
-						log.Fatalf("Failed to drain node: %s", err.Error())
-					}
-
-					log.Printf("Drain completed %s", usableNodes[nodeid])
-				}
-
-		case 5:
-			nodeid := rand.Intn(len(usableNodes))
-
-			return func() {
-					if err := uncordonNode(client, usableNodes[nodeid]); err != nil {
-						log.Fatalf("Failed to uncordon node: %s", err.Error())
-					}
-				}, func() {
-					gracePeriod := int64(0)
-
-					log.Printf("Draining node %s, with force and no grace-period", usableNodes[nodeid])
-					if err := drainNode(ctx, client, usableNodes[nodeid], &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
-						log.Fatalf("Failed to drain node: %s", err.Error())
-					}
-
-					log.Printf("Drain completed %s", usableNodes[nodeid])
-				}
-		case 6, 7, 8:
-			nodeid := rand.Intn(len(usableNodes))
-
-			return func() {
-					if err := uncordonNode(client, usableNodes[nodeid]); err != nil {
-						log.Fatalf("Failed to uncordon node: %s", err.Error())
-					}
-				}, func() {
-					gracePeriod := rand.Int63n(200) + 10
-
-					log.Printf("Draining node %s, with grace-period %d", usableNodes[nodeid], gracePeriod)
-					if err := drainNode(ctx, client, usableNodes[nodeid], &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
-						log.Fatalf("Failed to drain node: %s", err.Error())
-					}
-
-					log.Printf("Drain completed %s", usableNodes[nodeid])
-				}
-		case 9, 10:
-			nodeid := rand.Intn(len(usableNodes))
-
-			return func() {
-					if err := uncordonNode(client, usableNodes[nodeid]); err != nil {
-						log.Fatalf("Failed to uncordon node: %s", err.Error())
-					}
-				}, func() {
-					gracePeriod := int64(0)
-					log.Printf("Simulating crash of node %s", usableNodes[nodeid])
-					if err := simulateCrashNode(ctx, client, usableNodes[nodeid], &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
-						log.Fatalf("Failed to crash node: %s", err.Error())
-					}
-
-					log.Printf("Crash completed %s", usableNodes[nodeid])
-				}
+			fatalf("Chaos failed: %s", err.Error())
+		}
+	}
+	update := func(round *chaosRound) {
+		if err := replay.Update(round.Actions()); err != nil {
+			fatalf("Failed to record chaos: %s", err.Error())
 		}
+	}
 
-		return nil, nil
+	// Faults still running at the end of the chaos duration are stopped,
+	// their cleanups run with a background context. The virtual clock of a
+	// dry run is only checked by the schedule.
+	if deadline := schedule.Start(env.Now()); !deadline.IsZero() && !dryRun {
+		var cancelRun context.CancelFunc
+		ctx, cancelRun = context.WithDeadline(ctx, deadline)
+		defer cancelRun()
 	}
 
-	for {
-		var cleanupFuncs []func()
+	done := false
+	for n := 0; !done && (!dryRun || n < dryRunRounds); n++ {
+		round := newChaosRound()
+		var cleanups []pendingCleanup
+		var branches []ActionInterface
 		var wg sync.WaitGroup
-		i := 0
-		for {
-			clean, chaos := generateChaos()
-
-			if clean != nil {
-				cleanupFuncs = append(cleanupFuncs, clean)
+		var offset time.Duration
+		for i := 0; i < concurrent; i++ {
+			delay, ok, err := schedule.Wait(ctx, env)
+			if err != nil && ctx.Err() == nil {
+				fatalf("Failed to wait: %s", err.Error())
+			}
+			if !ok || err != nil {
+				done = true
+				break
 			}
 
-			wg.Add(1)
-			go func() {
-				chaos()
-				wg.Done()
-			}()
-			log.Printf("Started chaos")
+			if i == 0 {
+				round.Delay(delay)
+			} else {
+				offset += delay
+			}
 
-			i++
+			cleanup, chaos := generate()
 
-			if i == concurrent {
-				break
+			if cleanup != nil {
+				desc := *cleanup
+				cleanups = append(cleanups, pendingCleanup{
+					desc: desc,
+					run: env.Cleanups().Register(string(desc.Type)+" of a random fault", func() error {
+						return NewExecutor(env).Run(context.Background(), ActionDescriptionList{desc})
+					}),
+				})
 			}
 
-			timeout := rand.Intn(100)
-			log.Printf("Waiting %d seconds until next chaos", timeout)
-
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(time.Duration(timeout) * time.Second):
+			if chaos != nil {
+				round.AddChaos(offset, *chaos)
+				update(round)
+
+				branch := forkEnvironment(env)
+				branches = append(branches, branch)
+				wg.Add(1)
+				go func(chaos ActionDescription) {
+					run(ctx, branch, chaos)
+					wg.Done()
+				}(*chaos)
+				log.Printf("Started chaos")
 			}
 		}
 
 		wg.Wait()
+		joinEnvironment(env, branches)
 
-		for {
+		runCleanup := func(cleanup pendingCleanup) {
+			round.AddCleanup(cleanup.desc)
+			update(round)
+			if err := cleanup.run(); err != nil {
+				fatalf("Cleanup failed: %s", err.Error())
+			}
+		}
+
+		// Cleanups run even if the chaos is interrupted
+		healthy := false
+		for !healthy && ctx.Err() == nil {
 			timeout, cancel := context.WithTimeout(ctx, time.Minute)
-			if err := waitForDeploymentsReady(timeout); err == nil {
-				cancel()
-				break
-			} else if len(cleanupFuncs) > 0 {
+			if err := env.WaitForHealth(timeout); err == nil {
+				healthy = true
+			} else if len(cleanups) > 0 {
 				log.Printf("Deployment not ready, cleanup on chaos: %s", err.Error())
-				cf := cleanupFuncs[0]
-				cleanupFuncs = cleanupFuncs[1:]
-				cf()
+				cleanup := cleanups[0]
+				cleanups = cleanups[1:]
+				runCleanup(cleanup)
 			} else {
 				log.Printf("Deployment not ready: %s", err.Error())
 			}
 			cancel()
 		}
+		if healthy {
+			round.Healthy()
+		} else {
+			done = true
+		}
 
-		for _, cf := range cleanupFuncs {
-			cf()
+		for _, cleanup := range cleanups {
+			runCleanup(cleanup)
 		}
+
+		guard.Release()
+
+		if err := replay.Commit(round.Actions()); err != nil {
+			fatalf("Failed to record chaos: %s", err.Error())
+		}
+	}
+
+	env.Cleanups().RunAll()
+	if ctx.Err() == context.Canceled {
+		log.Printf("Chaos interrupted")
+		return
 	}
+	log.Printf("Chaos completed")
 
 	/*
 
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// netemDevice is the network interface of the pods
+const netemDevice = "eth0"
+
+// NetemOptions configure the network emulation of a pod
+type NetemOptions struct {
+	Latency time.Duration
+	Jitter  time.Duration
+	// Loss is the percentage of dropped packets
+	Loss float64
+	// HelperImage runs tc in a privileged pod on the node of the target
+	// instead of the arangod container. The image needs tc and nsenter.
+	HelperImage string
+	// Deadline is the time after which the emulation is removed by itself,
+	// even if the agent is gone
+	Deadline time.Duration
+}
+
+// args returns the netem arguments of tc qdisc
+func (o NetemOptions) args() []string {
+	args := []string{"netem"}
+	if o.Latency > 0 {
+		args = append(args, "delay", fmt.Sprintf("%dms", o.Latency.Milliseconds()))
+		if o.Jitter > 0 {
+			args = append(args, fmt.Sprintf("%dms", o.Jitter.Milliseconds()))
+		}
+	}
+	if o.Loss > 0 {
+		args = append(args, "loss", strconv.FormatFloat(o.Loss, 'f', -1, 64)+"%")
+	}
+	return args
+}
+
+func (o NetemOptions) String() string {
+	return strings.Join(o.args(), " ")
+}
+
+// addNetem adds the emulation to the network interface of the arangod
+// container. The container needs tc and the NET_ADMIN capability. With a
+// deadline, a background shell in the container removes the emulation once
+// the deadline and helperMargin passed.
+func addNetem(config *rest.Config, client k8s.Interface, namespace, name string, options NetemOptions) error {
+	command := append([]string{"tc", "qdisc", "replace", "dev", netemDevice, "root"}, options.args()...)
+	if options.Deadline > 0 {
+		deadline := int64((options.Deadline + helperMargin) / time.Second)
+		command = []string{"sh", "-c", fmt.Sprintf("%s || exit 1; (sleep %d; tc qdisc del dev %s root) </dev/null >/dev/null 2>&1 &",
+			strings.Join(command, " "), deadline, netemDevice)}
+	}
+	if _, err := execInPod(config, client, namespace, name, k8sutil.ServerContainerName, command); err != nil {
+		return err
+	}
+
+	log.Printf("Added %s to pod %s/%s", options, namespace, name)
+	return nil
+}
+
+// removeNetem removes the emulation added by addNetem
+func removeNetem(config *rest.Config, client k8s.Interface, namespace, name string) error {
+	command := []string{"tc", "qdisc", "del", "dev", netemDevice, "root"}
+	if _, err := execInPod(config, client, namespace, name, k8sutil.ServerContainerName, command); err != nil {
+		return err
+	}
+
+	log.Printf("Removed network emulation from pod %s/%s", namespace, name)
+	return nil
+}
+
+// netemHelper returns the helper adding the emulation inside the network
+// namespace of the container
+func netemHelper(options NetemOptions) helperOptions {
+	tc := "nsenter -t $pid -n tc qdisc "
+	return helperOptions{
+		Kind:     "netem",
+		Image:    options.HelperImage,
+		Apply:    tc + "replace dev " + netemDevice + " root " + options.String(),
+		Revert:   tc + "del dev " + netemDevice + " root",
+		Deadline: options.Deadline,
+	}
+}
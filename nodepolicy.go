@@ -0,0 +1,180 @@
+package main
+
+import (
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// NodePolicy decides which nodes the random chaos may target. Nodes must
+// be allowed by the selector, schedulable, ready and all their taints must
+// be tolerated by the ArangoDB pods. The usable nodes are cached for the
+// refresh interval, so nodes added or removed by autoscaling are noticed.
+type NodePolicy struct {
+	client     k8s.Interface
+	namespaces []string
+	selector   TargetSelector
+	refresh    time.Duration
+
+	mutex   sync.Mutex
+	nodes   []string
+	labels  map[string]map[string]string
+	updated time.Time
+}
+
+// NewNodePolicy creates a policy for the ArangoDB pods of the namespaces
+func NewNodePolicy(client k8s.Interface, namespaces []string, selector TargetSelector, refresh time.Duration) *NodePolicy {
+	return &NodePolicy{
+		client:     client,
+		namespaces: namespaces,
+		selector:   selector,
+		refresh:    refresh,
+	}
+}
+
+// Usable returns the nodes the random chaos may target
+func (p *NodePolicy) Usable() ([]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.update(); err != nil {
+		return nil, err
+	}
+	return p.nodes, nil
+}
+
+// Zones returns the usable nodes grouped by the value of the zone label.
+// Nodes without the label are ignored.
+func (p *NodePolicy) Zones(label string) (map[string][]string, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.update(); err != nil {
+		return nil, err
+	}
+
+	zones := make(map[string][]string)
+	for _, name := range p.nodes {
+		if zone, ok := p.labels[name][label]; ok {
+			zones[zone] = append(zones[zone], name)
+		}
+	}
+	return zones, nil
+}
+
+// update refreshes the usable nodes once the refresh interval has passed
+func (p *NodePolicy) update() error {
+	if !p.updated.IsZero() && time.Since(p.updated) < p.refresh {
+		return nil
+	}
+
+	tolerations, err := p.tolerations()
+	if err != nil {
+		return err
+	}
+
+	list, err := p.client.CoreV1().Nodes().List(metav1.ListOptions{
+		LabelSelector: p.selector.nodeLabelSelector(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to obtain node list")
+	}
+
+	var nodes []string
+	labels := make(map[string]map[string]string)
+	reasons := make(map[string]string)
+	for i := range list.Items {
+		node := &list.Items[i]
+		if reason := p.ineligible(node, tolerations); reason != "" {
+			reasons[node.GetName()] = reason
+			continue
+		}
+		nodes = append(nodes, node.GetName())
+		labels[node.GetName()] = node.GetLabels()
+	}
+
+	if !reflect.DeepEqual(nodes, p.nodes) {
+		for name, reason := range reasons {
+			log.Printf("Can not use node %s, %s", name, reason)
+		}
+		for _, name := range nodes {
+			log.Printf("Using node %s", name)
+		}
+	}
+
+	p.nodes = nodes
+	p.labels = labels
+	p.updated = time.Now()
+	return nil
+}
+
+// ineligible returns why the node may not be targeted or an empty string
+func (p *NodePolicy) ineligible(node *v1.Node, tolerations []v1.Toleration) string {
+	if !p.selector.AllowsNode(node) {
+		return "excluded"
+	}
+
+	if node.Spec.Unschedulable {
+		return "unschedulable"
+	}
+
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Key == simulatedCrashTaintKey {
+			return "crashed"
+		}
+		if taint.Effect == v1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(tolerations, taint) {
+			return "taint " + taint.ToString() + " not tolerated"
+		}
+	}
+
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			if cond.Status == v1.ConditionTrue {
+				return ""
+			}
+			break
+		}
+	}
+
+	return "not ready"
+}
+
+// tolerations returns the tolerations of all ArangoDB pods of the namespaces
+func (p *NodePolicy) tolerations() ([]v1.Toleration, error) {
+	var tolerations []v1.Toleration
+
+	for _, namespace := range p.namespaces {
+		pods, err := p.client.CoreV1().Pods(namespace).List(metav1.ListOptions{
+			LabelSelector: k8sutil.LabelKeyArangoDeployment,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list pods")
+		}
+
+		for _, pod := range pods.Items {
+			tolerations = append(tolerations, pod.Spec.Tolerations...)
+		}
+	}
+
+	return tolerations, nil
+}
+
+func toleratesTaint(tolerations []v1.Toleration, taint *v1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
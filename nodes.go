@@ -16,26 +16,96 @@ import (
 
 // nodeManager manages nodes of the kubernets cluster
 type nodeManager struct {
-	client k8s.Interface
+	client     k8s.Interface
+	terminator NodeTerminator
+	selector   TargetSelector
+	policy     *NodePolicy
 }
 
 type Node interface {
+	// Name returns the name of the node
+	Name() string
+
 	Cordon() error
 	Uncordon() error
 	IsCordoned() (bool, error)
 
-	Drain() error
+	// Drain cordons the node and evicts its pods
+	Drain(ctx context.Context, options DrainOptions) (*DrainResult, error)
+	// Kill terminates the node using the configured node terminator
+	Kill(ctx context.Context) error
+	// Restore brings a killed node back
+	Restore(ctx context.Context) error
 }
 
 type NodeManager interface {
 	Node(name string) Node
+	// Allowed returns an error if the target selector excludes the node
+	Allowed(name string) error
+	// Usable returns the nodes the random chaos may target
+	Usable() ([]string, error)
+	// Zones returns the usable nodes grouped by the value of the zone label
+	Zones(label string) (map[string][]string, error)
+}
+
+// NodeTarget selects a node either by name or by a pod running on it
+type NodeTarget struct {
+	Name string     `json:"name,omitempty"`
+	Pod  *PodTarget `json:"pod,omitempty"`
+}
+
+// Validate checks that exactly one way of selecting the node is given
+func (t NodeTarget) Validate() error {
+	if t.Name != "" && t.Pod != nil {
+		return errors.New("name and pod are mutually exclusive")
+	}
+	if t.Pod != nil {
+		return withPath("pod", t.Pod.Validate())
+	}
+	if t.Name == "" {
+		return errors.New("requires a name or a pod")
+	}
+	return nil
+}
+
+// Resolve returns the node selected by the target
+func (t NodeTarget) Resolve(ctx context.Context, iface ActionInterface) (Node, error) {
+	if t.Name != "" {
+		if err := iface.Nodes().Allowed(t.Name); err != nil {
+			return nil, err
+		}
+		return iface.Nodes().Node(t.Name), nil
+	}
+
+	if t.Pod != nil {
+		pod, err := targetPod(ctx, iface, *t.Pod)
+		if err != nil {
+			return nil, err
+		}
+
+		node, err := pod.Node()
+		if err != nil {
+			return nil, err
+		}
+		if err := iface.Nodes().Allowed(node.Name()); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	return nil, errors.New("node target requires a name or a pod")
 }
 
 // NewNodeManager creates a new node manager that connects to the
-// cluster using the given client
-func NewNodeManager(client k8s.Interface) (NodeManager, error) {
+// cluster using the given client and kills nodes using the terminator.
+// Only nodes allowed by the selector are targeted, the random chaos only
+// targets nodes eligible according to the policy.
+func NewNodeManager(client k8s.Interface, terminator NodeTerminator, selector TargetSelector, policy *NodePolicy) (NodeManager, error) {
 	return &nodeManager{
-		client: client,
+		client:     client,
+		terminator: terminator,
+		selector:   selector,
+		policy:     policy,
 	}, nil
 }
 
@@ -64,6 +134,30 @@ func (nm *nodeManager) Node(name string) Node {
 	}
 }
 
+func (nm *nodeManager) Allowed(name string) error {
+	node, err := nm.client.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get node %s", name)
+	}
+	if !nm.selector.AllowsNode(node) {
+		return errors.Errorf("node %s is not allowed by the target selector", name)
+	}
+	return nil
+}
+
+// Usable returns the nodes eligible according to the node policy
+func (nm *nodeManager) Usable() ([]string, error) {
+	return nm.policy.Usable()
+}
+
+func (nm *nodeManager) Zones(label string) (map[string][]string, error) {
+	return nm.policy.Zones(label)
+}
+
+func (n *node) Name() string {
+	return n.name
+}
+
 func (n *node) Cordon() error {
 	return n.manager.CordonNode(n.name)
 }
@@ -76,8 +170,16 @@ func (n *node) IsCordoned() (bool, error) {
 	return n.manager.IsNodeCordoned(n.name)
 }
 
-func (n *node) Drain() error {
-	return n.manager.DrainNode(n.name)
+func (n *node) Drain(ctx context.Context, options DrainOptions) (*DrainResult, error) {
+	return n.manager.DrainNode(ctx, n.name, options)
+}
+
+func (n *node) Kill(ctx context.Context) error {
+	return n.manager.terminator.Terminate(ctx, n.name)
+}
+
+func (n *node) Restore(ctx context.Context) error {
+	return n.manager.terminator.Restore(ctx, n.name)
 }
 
 // PatchNodeUnschedulable patches the Spec.Unschedulable field of a node
@@ -128,31 +230,6 @@ func (nm *nodeManager) IsNodeCordoned(name string) (bool, error) {
 	return node.Spec.Unschedulable, nil
 }
 
-// DrainNode drains the given node by cordon it and
-// evicting all pods.
-func (nm *nodeManager) DrainNode(name string) error {
-
-	// Drain node does the following:
-	// 	CordonNode
-	// 	Evict all Pods on that node
-	if err := nm.CordonNode(name); err != nil {
-		return err
-	}
-
-	/*pods, err := nm.GetNodePods(name)
-	if err != nil {
-		return err
-	}
-
-	/*for _, pod := range pods {
-		if err := EvictPod(nm.client, pod.Namespace, pod.Name); err != nil {
-			return err
-		}
-	}*/
-
-	return nil
-}
-
 func patchNodeUnschedulable(client k8s.Interface, name string, state bool) error {
 	bytes, err := json.Marshal(newNodePatchUnschedulable(state))
 	if err != nil {
@@ -189,20 +266,6 @@ func getNodePods(client k8s.Interface, name string) ([]v1.Pod, error) {
 	return list.Items, nil
 }
 
-func drainNode(ctx context.Context, client k8s.Interface, name string, options *metav1.DeleteOptions) error {
-	if err := cordonNode(client, name); err != nil {
-		return errors.Wrap(err, "failed to drain node")
-	}
-
-	if err := runForEachPodOnNode(ctx, client, name, func(pod *v1.Pod) error {
-		return evictPod(ctx, client, pod.GetName(), pod.GetNamespace(), options)
-	}); err != nil {
-		return errors.Wrap(err, "failed to drain node")
-	}
-
-	return nil
-}
-
 func simulateCrashNode(ctx context.Context, client k8s.Interface, name string, options *metav1.DeleteOptions) error {
 	if err := cordonNode(client, name); err != nil {
 		return errors.Wrap(err, "failed to crash node")
@@ -232,13 +295,7 @@ func runForEachPodOnNode(ctx context.Context, client k8s.Interface, name string,
 
 	for _, pod := range pods {
 
-		// Ignore daemonsets
-		controller := metav1.GetControllerOf(&pod)
-		if controller != nil && controller.Kind == "DaemonSet" {
-			continue
-		}
-		// Ignore mirror pods
-		if _, found := pod.ObjectMeta.Annotations[v1.MirrorPodAnnotationKey]; found {
+		if drainSkipReason(&pod) != "" {
 			continue
 		}
 
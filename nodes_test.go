@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// deletedNodeManager is a node manager whose nodes no longer exist
+type deletedNodeManager struct {
+	NodeManager
+}
+
+func (nm *deletedNodeManager) Allowed(name string) error {
+	err := apierrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, name)
+	return errors.Wrapf(err, "failed to get node %s", name)
+}
+
+// nodeEnvironment is an environment using the given node manager
+type nodeEnvironment struct {
+	ActionInterface
+	nodes NodeManager
+}
+
+func (e *nodeEnvironment) Nodes() NodeManager {
+	return e.nodes
+}
+
+func TestRestoreDeletedNode(t *testing.T) {
+	terminator := newFakeNodeTerminator()
+	nodes, err := NewNodeManager(nil, terminator, TargetSelector{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	env := &nodeEnvironment{nodes: &deletedNodeManager{NodeManager: nodes}}
+
+	ctx := context.Background()
+	if err := nodes.Node("node-1").Kill(ctx); err != nil {
+		t.Fatalf("kill failed: %s", err)
+	}
+
+	restore := &actionRestoreNode{target: NodeTarget{Name: "node-1"}}
+	if err := restore.Run(ctx, env); err != nil {
+		t.Fatalf("restore of a deleted node failed: %s", err)
+	}
+	if terminator.IsTerminated("node-1") {
+		t.Errorf("node-1 still terminated after restore")
+	}
+
+	drain := &actionDrainNode{target: NodeTarget{Name: "node-1"}}
+	if err := drain.Run(ctx, env); !apierrors.IsNotFound(errors.Cause(err)) {
+		t.Errorf("expected drain of a deleted node to fail with not found, got %v", err)
+	}
+}
@@ -1 +1,333 @@
 package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	apiextension "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// Operator controls the kube-arangodb operator of the namespace
+type Operator interface {
+	// Deploy installs the operator using the given image. An already
+	// installed operator is upgraded to the image.
+	Deploy(ctx context.Context, image string) error
+	// Delete removes the operator. The CRD is only removed if deleteCRD is
+	// set, since that also removes all ArangoDeployments.
+	Delete(ctx context.Context, deleteCRD bool) error
+}
+
+const (
+	operatorName          = "arango-deployment-operator"
+	operatorContainerName = "operator"
+	operatorPort          = 8528
+
+	arangoDeploymentCRDName = "arangodeployments.database.arangodb.com"
+)
+
+// operator installs the deployment operator of kube-arangodb into a namespace
+type operator struct {
+	client    k8s.Interface
+	api       apiextension.Interface
+	namespace string
+}
+
+// NewOperator creates an operator control for the given namespace
+func NewOperator(client k8s.Interface, api apiextension.Interface, namespace string) (Operator, error) {
+	return &operator{
+		client:    client,
+		api:       api,
+		namespace: namespace,
+	}, nil
+}
+
+// clusterName returns the name of cluster wide resources, which must be unique per namespace
+func (o *operator) clusterName() string {
+	return operatorName + "-" + o.namespace
+}
+
+func operatorLabels() map[string]string {
+	return map[string]string{"app": operatorName}
+}
+
+func ignoreAlreadyExists(err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (o *operator) newCRD() *apiextensionsv1beta1.CustomResourceDefinition {
+	return &apiextensionsv1beta1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: arangoDeploymentCRDName,
+		},
+		Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+			Group:   "database.arangodb.com",
+			Version: "v1alpha",
+			Scope:   apiextensionsv1beta1.NamespaceScoped,
+			Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+				Plural:     "arangodeployments",
+				Singular:   "arangodeployment",
+				Kind:       "ArangoDeployment",
+				ListKind:   "ArangoDeploymentList",
+				ShortNames: []string{"arangodb", "arango"},
+			},
+		},
+	}
+}
+
+func (o *operator) newClusterRole() *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   o.clusterName(),
+			Labels: operatorLabels(),
+		},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"get"}},
+			{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"storage.k8s.io"}, Resources: []string{"storageclasses"}, Verbs: []string{"get", "list"}},
+		},
+	}
+}
+
+func (o *operator) newRole() *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   operatorName,
+			Labels: operatorLabels(),
+		},
+		Rules: []rbacv1.PolicyRule{
+			{APIGroups: []string{"database.arangodb.com"}, Resources: []string{"arangodeployments"}, Verbs: []string{"*"}},
+			{APIGroups: []string{""}, Resources: []string{"pods", "services", "endpoints", "persistentvolumeclaims", "events", "secrets"}, Verbs: []string{"*"}},
+			{APIGroups: []string{"apps"}, Resources: []string{"deployments", "replicasets"}, Verbs: []string{"get"}},
+			{APIGroups: []string{"policy"}, Resources: []string{"poddisruptionbudgets"}, Verbs: []string{"*"}},
+		},
+	}
+}
+
+func (o *operator) subjects() []rbacv1.Subject {
+	return []rbacv1.Subject{
+		{Kind: rbacv1.ServiceAccountKind, Name: operatorName, Namespace: o.namespace},
+	}
+}
+
+func (o *operator) newDeployment(image string) *appsv1.Deployment {
+	replicas := int32(1)
+	fieldEnv := func(name, path string) v1.EnvVar {
+		return v1.EnvVar{
+			Name:      name,
+			ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: path}},
+		}
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   operatorName,
+			Labels: operatorLabels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: operatorLabels()},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: operatorLabels()},
+				Spec: v1.PodSpec{
+					ServiceAccountName: operatorName,
+					Containers: []v1.Container{
+						{
+							Name:            operatorContainerName,
+							Image:           image,
+							ImagePullPolicy: v1.PullIfNotPresent,
+							Args:            []string{"--operator.deployment", "--chaos.allowed=false"},
+							Env: []v1.EnvVar{
+								fieldEnv("MY_POD_NAMESPACE", "metadata.namespace"),
+								fieldEnv("MY_POD_NAME", "metadata.name"),
+								fieldEnv("MY_POD_IP", "status.podIP"),
+							},
+							Ports: []v1.ContainerPort{
+								{Name: "metrics", ContainerPort: operatorPort},
+							},
+							ReadinessProbe: &v1.Probe{
+								Handler: v1.Handler{
+									HTTPGet: &v1.HTTPGetAction{
+										Path:   "/ready",
+										Port:   intstr.FromInt(operatorPort),
+										Scheme: v1.URISchemeHTTPS,
+									},
+								},
+								InitialDelaySeconds: 5,
+								PeriodSeconds:       10,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Deploy creates the CRD, RBAC resources and the operator deployment and
+// waits until an operator pod running the image is ready
+func (o *operator) Deploy(ctx context.Context, image string) error {
+	log.Printf("Deploying operator %s into %s", image, o.namespace)
+
+	if _, err := o.api.ApiextensionsV1beta1().CustomResourceDefinitions().Create(o.newCRD()); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create crd")
+	}
+
+	sa := &v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: operatorName, Labels: operatorLabels()}}
+	if _, err := o.client.CoreV1().ServiceAccounts(o.namespace).Create(sa); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create service account")
+	}
+
+	if _, err := o.client.RbacV1().ClusterRoles().Create(o.newClusterRole()); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create cluster role")
+	}
+
+	clusterBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: o.clusterName(), Labels: operatorLabels()},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: o.clusterName()},
+		Subjects:   o.subjects(),
+	}
+	if _, err := o.client.RbacV1().ClusterRoleBindings().Create(clusterBinding); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create cluster role binding")
+	}
+
+	if _, err := o.client.RbacV1().Roles(o.namespace).Create(o.newRole()); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create role")
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: operatorName, Labels: operatorLabels()},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: operatorName},
+		Subjects:   o.subjects(),
+	}
+	if _, err := o.client.RbacV1().RoleBindings(o.namespace).Create(binding); ignoreAlreadyExists(err) != nil {
+		return errors.Wrap(err, "failed to create role binding")
+	}
+
+	deployments := o.client.AppsV1().Deployments(o.namespace)
+	if _, err := deployments.Create(o.newDeployment(image)); apierrors.IsAlreadyExists(err) {
+		// Upgrade the existing operator
+		current, err := deployments.Get(operatorName, metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrap(err, "failed to get operator deployment")
+		}
+
+		for i := range current.Spec.Template.Spec.Containers {
+			if current.Spec.Template.Spec.Containers[i].Name == operatorContainerName {
+				current.Spec.Template.Spec.Containers[i].Image = image
+			}
+		}
+
+		log.Printf("Upgrading operator to %s", image)
+		if _, err := deployments.Update(current); err != nil {
+			return errors.Wrap(err, "failed to update operator deployment")
+		}
+	} else if err != nil {
+		return errors.Wrap(err, "failed to create operator deployment")
+	}
+
+	if err := retry(ctx, func() error {
+		return o.checkOperatorReady(image)
+	}); err != nil {
+		return errors.Wrap(err, "operator did not become ready")
+	}
+
+	log.Printf("Operator %s ready", image)
+	return nil
+}
+
+// checkOperatorReady checks that all operator pods run the image and at least one is ready
+func (o *operator) checkOperatorReady(image string) error {
+	pods, err := o.client.CoreV1().Pods(o.namespace).List(metav1.ListOptions{LabelSelector: operatorLabelSelector})
+	if err != nil {
+		return err
+	}
+
+	ready := false
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			if c.Name == operatorContainerName && c.Image != image {
+				return fmt.Errorf("operator pod %s still runs %s", pod.GetName(), c.Image)
+			}
+		}
+		if isPodReady(&pod) {
+			ready = true
+		}
+	}
+
+	if !ready {
+		return errors.New("no operator pod ready")
+	}
+	return nil
+}
+
+// Delete removes the operator deployment and RBAC resources and waits
+// until all operator pods are gone
+func (o *operator) Delete(ctx context.Context, deleteCRD bool) error {
+	log.Printf("Deleting operator from %s", o.namespace)
+
+	propagation := metav1.DeletePropagationForeground
+	options := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	if err := o.client.AppsV1().Deployments(o.namespace).Delete(operatorName, options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete operator deployment")
+	}
+
+	if err := retry(ctx, func() error {
+		pods, err := o.client.CoreV1().Pods(o.namespace).List(metav1.ListOptions{LabelSelector: operatorLabelSelector})
+		if err != nil {
+			return err
+		}
+		if len(pods.Items) > 0 {
+			return fmt.Errorf("%d operator pods remaining", len(pods.Items))
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "operator pods did not disappear")
+	}
+
+	if err := o.client.RbacV1().RoleBindings(o.namespace).Delete(operatorName, options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete role binding")
+	}
+	if err := o.client.RbacV1().Roles(o.namespace).Delete(operatorName, options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete role")
+	}
+	if err := o.client.RbacV1().ClusterRoleBindings().Delete(o.clusterName(), options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete cluster role binding")
+	}
+	if err := o.client.RbacV1().ClusterRoles().Delete(o.clusterName(), options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete cluster role")
+	}
+	if err := o.client.CoreV1().ServiceAccounts(o.namespace).Delete(operatorName, options); ignoreNotFound(err) != nil {
+		return errors.Wrap(err, "failed to delete service account")
+	}
+
+	if deleteCRD {
+		log.Printf("Deleting CRD %s", arangoDeploymentCRDName)
+		if err := o.api.ApiextensionsV1beta1().CustomResourceDefinitions().Delete(arangoDeploymentCRDName, options); ignoreNotFound(err) != nil {
+			return errors.Wrap(err, "failed to delete crd")
+		}
+	}
+
+	log.Printf("Operator deleted from %s", o.namespace)
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/pkg/errors"
+	networking "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	k8sretry "k8s.io/client-go/util/retry"
+)
+
+const (
+	// partitionLabelPrefix is the prefix of the pod label marking the sides
+	// of a partition. The label is named after the isolated pod, so a pod
+	// can be a peer of several partitions at once.
+	partitionLabelPrefix = "partition.chaos.arangodb.com/"
+	// partitionPolicyPrefix is the name prefix of the NetworkPolicies
+	partitionPolicyPrefix = "chaos-partition-"
+
+	partitionIsolated = "isolated"
+	partitionPeer     = "peer"
+)
+
+// partitionLabel returns the label marking the sides of the partition of the pod
+func partitionLabel(isolated string) string {
+	return partitionLabelPrefix + isolated
+}
+
+// setPodLabel sets or, with an empty value, removes a label of the pod.
+// Ignores if the pod is not found.
+func setPodLabel(client k8s.Interface, namespace, name, key, value string) error {
+	return k8sretry.RetryOnConflict(k8sretry.DefaultRetry, func() error {
+		pod, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		labels := pod.GetLabels()
+		if value == "" {
+			if _, ok := labels[key]; !ok {
+				return nil
+			}
+			delete(labels, key)
+		} else {
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			labels[key] = value
+		}
+
+		pod.SetLabels(labels)
+		_, err = client.CoreV1().Pods(namespace).Update(pod)
+		return err
+	})
+}
+
+// allAddresses are the CIDRs of all IPv4 and IPv6 addresses
+var allAddresses = []string{"0.0.0.0/0", "::/0"}
+
+// podIPs returns the IPs of the given pods, ignoring pods without IP
+func podIPs(client k8s.Interface, namespace string, names []string) ([]string, error) {
+	var ips []string
+	for _, name := range names {
+		pod, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if pod.Status.PodIP != "" {
+			ips = append(ips, pod.Status.PodIP)
+		}
+	}
+	return ips, nil
+}
+
+// partitionPolicy creates a policy for the pods on one side of the partition,
+// denying ingress from the pods on the other side. Traffic from everywhere
+// else stays allowed, including sources that are not pods like external
+// clients and kubelet probes, which are matched by IP blocks excluding the
+// IPs of the other side.
+func partitionPolicy(isolated, side, other string, otherIPs []string) *networking.NetworkPolicy {
+	label := partitionLabel(isolated)
+
+	from := []networking.NetworkPolicyPeer{
+		{
+			NamespaceSelector: &metav1.LabelSelector{},
+			PodSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: label, Operator: metav1.LabelSelectorOpNotIn, Values: []string{other}},
+				},
+			},
+		},
+	}
+	for _, cidr := range allAddresses {
+		ipv6 := strings.Contains(cidr, ":")
+		var except []string
+		for _, ip := range otherIPs {
+			if strings.Contains(ip, ":") != ipv6 {
+				continue
+			}
+			if ipv6 {
+				except = append(except, ip+"/128")
+			} else {
+				except = append(except, ip+"/32")
+			}
+		}
+		from = append(from, networking.NetworkPolicyPeer{
+			IPBlock: &networking.IPBlock{CIDR: cidr, Except: except},
+		})
+	}
+
+	return &networking.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: partitionPolicyPrefix + isolated + "-" + side,
+		},
+		Spec: networking.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{label: side},
+			},
+			PolicyTypes: []networking.PolicyType{networking.PolicyTypeIngress},
+			Ingress:     []networking.NetworkPolicyIngressRule{{From: from}},
+		},
+	}
+}
+
+// partitionPod cuts the network between the isolated pod and its peers in
+// both directions. Connections are refused on ingress of either side.
+func partitionPod(client k8s.Interface, namespace, isolated string, peers []string) error {
+	label := partitionLabel(isolated)
+
+	if err := setPodLabel(client, namespace, isolated, label, partitionIsolated); err != nil {
+		return errors.Wrapf(err, "failed to label pod %s", isolated)
+	}
+	for _, peer := range peers {
+		if err := setPodLabel(client, namespace, peer, label, partitionPeer); err != nil {
+			return errors.Wrapf(err, "failed to label pod %s", peer)
+		}
+	}
+
+	isolatedIPs, err := podIPs(client, namespace, []string{isolated})
+	if err != nil {
+		return errors.Wrap(err, "failed to get pod IPs")
+	}
+	peerIPs, err := podIPs(client, namespace, peers)
+	if err != nil {
+		return errors.Wrap(err, "failed to get pod IPs")
+	}
+
+	for _, policy := range []*networking.NetworkPolicy{
+		partitionPolicy(isolated, partitionIsolated, partitionPeer, peerIPs),
+		partitionPolicy(isolated, partitionPeer, partitionIsolated, isolatedIPs),
+	} {
+		_, err := client.NetworkingV1().NetworkPolicies(namespace).Create(policy)
+		if apierrors.IsAlreadyExists(err) {
+			continue
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to create network policy %s", policy.GetName())
+		}
+	}
+
+	log.Printf("Pod %s/%s partitioned from %v", namespace, isolated, peers)
+	return nil
+}
+
+// healPartition removes the network policies and labels of the partition
+// of the isolated pod. Ignores if there is no such partition.
+func healPartition(client k8s.Interface, namespace, isolated string) error {
+	for _, side := range []string{partitionIsolated, partitionPeer} {
+		name := partitionPolicyPrefix + isolated + "-" + side
+		err := client.NetworkingV1().NetworkPolicies(namespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete network policy %s", name)
+		}
+	}
+
+	label := partitionLabel(isolated)
+	pods, err := client.CoreV1().Pods(namespace).List(metav1.ListOptions{LabelSelector: label})
+	if err != nil {
+		return errors.Wrap(err, "failed to list partitioned pods")
+	}
+
+	for _, pod := range pods.Items {
+		if err := setPodLabel(client, namespace, pod.GetName(), label, ""); err != nil {
+			return errors.Wrapf(err, "failed to remove label of pod %s", pod.GetName())
+		}
+	}
+
+	log.Printf("Partition of pod %s/%s healed", namespace, isolated)
+	return nil
+}
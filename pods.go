@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"math/rand"
+	"strings"
 	"time"
 
+	arangoapi "github.com/arangodb/kube-arangodb/pkg/apis/deployment/v1alpha"
+	arangoclient "github.com/arangodb/kube-arangodb/pkg/generated/clientset/versioned/typed/deployment/v1alpha"
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
 	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
 	policy "k8s.io/api/policy/v1beta1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	fields "k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	watch "k8s.io/apimachinery/pkg/watch"
 	k8s "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 	api "k8s.io/kubernetes/pkg/apis/core"
 )
 
@@ -19,39 +30,617 @@ type PodGroup string
 
 const (
 	PodGroupOperator    PodGroup = "Operator"
-	PodGroupAgent       PodGroup = "AgentLeader"
+	PodGroupAgent       PodGroup = "Agent"
 	PodGroupCoordinator PodGroup = "Coordinator"
 	PodGroupDBServer    PodGroup = "DBServer"
 )
 
+// PodTarget selects a pod by its role. Deployment and Namespace restrict
+// the selection to a single ArangoDeployment or namespace. Name selects a
+// single pod instead of a role.
 type PodTarget struct {
-	Group    PodGroup
-	IsLeader bool
-	IsReady  bool
+	Name       string   `json:"name,omitempty"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Group      PodGroup `json:"group,omitempty"`
+	IsLeader   bool     `json:"isLeader"`
+	IsReady    bool     `json:"isReady"`
+	Deployment string   `json:"deployment,omitempty"`
+}
+
+// IsValid returns true if the group is one of the known pod groups
+func (g PodGroup) IsValid() bool {
+	switch g {
+	case PodGroupOperator, PodGroupAgent, PodGroupCoordinator, PodGroupDBServer:
+		return true
+	}
+	return false
+}
+
+// Validate checks that the target names a pod or describes a known pod group
+func (t PodTarget) Validate() error {
+	if t.Name != "" {
+		if t.Group != "" {
+			return errors.New("name and group are mutually exclusive")
+		}
+		return nil
+	}
+	if t.Group == "" {
+		return withPath("group", fmt.Errorf("required"))
+	}
+	if !t.Group.IsValid() {
+		return withPath("group", fmt.Errorf("unknown pod group %q", t.Group))
+	}
+	if t.IsLeader && t.Group != PodGroupAgent {
+		return withPath("isLeader", fmt.Errorf("only supported for group %s", PodGroupAgent))
+	}
+	return nil
 }
 
 type Pod interface {
+	// Name returns the name of the pod
+	Name() string
+	// Namespace returns the namespace of the pod
+	Namespace() string
+
 	// Evict creates a Eviction for the Pod
-	Evict(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions) error
+	Evict(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions, eviction EvictionOptions) error
 	// Delete deletes the pod
 	Delete(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions) error
+	// DeletePersistentVolumeClaims deletes all PVCs mounted by the pod. If
+	// removeFinalizer is set the pvc-protection finalizer is removed, so the
+	// PVCs disappear while still in use. Completion is signaled once all
+	// PVCs are gone.
+	DeletePersistentVolumeClaims(ctx context.Context, completion chan<- error, removeFinalizer bool, options *metav1.DeleteOptions) error
+
+	// Isolate cuts the network between the pod and the given peer pods of
+	// the same namespace until Reconnect is called
+	Isolate(ctx context.Context, peers []string) error
+	// Reconnect removes the network partition created by Isolate
+	Reconnect(ctx context.Context) error
+	// Netem adds delay, jitter and packet loss to the network of the pod
+	Netem(ctx context.Context, options NetemOptions) error
+	// ClearNetem removes the emulation added by Netem
+	ClearNetem(ctx context.Context, options NetemOptions) error
+	// Freeze stops the processes of the arangod container using a helper
+	// pod with the given image, until Thaw is called or the deadline passed
+	Freeze(ctx context.Context, image string, deadline time.Duration) error
+	// Thaw continues the processes stopped by Freeze
+	Thaw(ctx context.Context) error
+	// Signal sends the signal to the arangod process. SIGKILL is sent by a
+	// helper pod with the given image.
+	Signal(ctx context.Context, signal ProcessSignal, helperImage string) error
+	// RestartCount returns the restart count of the arangod container and
+	// whether it is running
+	RestartCount() (int32, bool, error)
+	// FillVolume fills the data volume to the percentage of its size
+	FillVolume(ctx context.Context, percent int) error
+	// FreeVolume frees the space taken by FillVolume
+	FreeVolume(ctx context.Context) error
+
+	// UID returns the UID of the current pod with the name
+	UID() (types.UID, error)
+	// WaitForReplacement waits until a pod with the same name but another
+	// UID exists, i.e. the operator replaced the member
+	WaitForReplacement(ctx context.Context, uid types.UID) error
+
+	// Deployment returns the name of the ArangoDeployment owning the pod
+	Deployment() (string, error)
 
 	// Node returns the Node of this Pod
 	Node() (Node, error)
 }
 
 type PodManager interface {
-	// Pod returns the pod with the given name
+	// Pod returns the pod with the given name. The name may be qualified
+	// as namespace/name, otherwise the first namespace is used.
 	Pod(name string) Pod
 
 	// Target returns a pod satisfying the given target constraints or nil
 	// if there is no such pod.
-	Target(target PodTarget) Pod
+	Target(ctx context.Context, target PodTarget) (Pod, error)
+
+	// TargetCandidates returns the namespace/name keys of all pods
+	// satisfying the target constraints.
+	TargetCandidates(ctx context.Context, target PodTarget) ([]string, error)
+}
+
+// operatorLabelSelector selects the pods of the kube-arangodb deployment operator
+const operatorLabelSelector = "app=arango-deployment-operator"
+
+// podManager resolves pods of the ArangoDB deployments in a set of namespaces
+type podManager struct {
+	config     *rest.Config
+	client     k8s.Interface
+	arango     arangoclient.DatabaseV1alphaInterface
+	namespaces []string
+	nodes      NodeManager
+	selector   TargetSelector
+}
+
+// NewPodManager creates a pod manager for the given namespaces. Only pods
+// allowed by the selector are targeted.
+func NewPodManager(config *rest.Config, client k8s.Interface, arango arangoclient.DatabaseV1alphaInterface, namespaces []string, nodes NodeManager, selector TargetSelector) (PodManager, error) {
+	if len(namespaces) == 0 {
+		return nil, errors.New("no namespace given")
+	}
+
+	return &podManager{
+		config:     config,
+		client:     client,
+		arango:     arango,
+		namespaces: namespaces,
+		nodes:      nodes,
+		selector:   selector,
+	}, nil
+}
+
+type pod struct {
+	manager   *podManager
+	namespace string
+	name      string
+}
+
+// Pod returns the control interface for the given pod
+func (pm *podManager) Pod(name string) Pod {
+	namespace, name, err := cache.SplitMetaNamespaceKey(name)
+	if err != nil || namespace == "" {
+		namespace = pm.namespaces[0]
+	}
+
+	return &pod{
+		manager:   pm,
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+// Target selects a random pod among all pods satisfying the target
+func (pm *podManager) Target(ctx context.Context, target PodTarget) (Pod, error) {
+	candidates, err := pm.TargetCandidates(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	return pm.Pod(candidates[rand.Intn(len(candidates))]), nil
+}
+
+// TargetCandidates returns the namespace/name keys of all pods satisfying
+// the target and allowed by the selector
+func (pm *podManager) TargetCandidates(ctx context.Context, target PodTarget) ([]string, error) {
+	namespaces := pm.namespaces
+	if target.Namespace != "" {
+		if !containsString(pm.namespaces, target.Namespace) {
+			return nil, errors.Errorf("namespace %s is not managed", target.Namespace)
+		}
+		namespaces = []string{target.Namespace}
+	}
+
+	var candidates []string
+	for _, namespace := range namespaces {
+		names, err := pm.targetPodNames(ctx, namespace, target)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := pm.client.CoreV1().Pods(namespace).List(metav1.ListOptions{
+			LabelSelector: pm.selector.podLabelSelector(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list pods")
+		}
+
+		pods := make(map[string]*v1.Pod)
+		for i := range list.Items {
+			pods[list.Items[i].GetName()] = &list.Items[i]
+		}
+
+		for _, name := range names {
+			p, ok := pods[name]
+			if !ok || !pm.selector.AllowsPod(p) {
+				continue
+			}
+			if target.IsReady && !isPodReady(p) {
+				continue
+			}
+			candidates = append(candidates, namespace+"/"+name)
+		}
+	}
+
+	return candidates, nil
+}
+
+// targetPodNames returns the names of the pods of the target group in the namespace
+func (pm *podManager) targetPodNames(ctx context.Context, namespace string, target PodTarget) ([]string, error) {
+	var names []string
+
+	if target.Name != "" {
+		return []string{target.Name}, nil
+	}
+
+	if target.Group == PodGroupOperator {
+		list, err := pm.client.CoreV1().Pods(namespace).List(metav1.ListOptions{
+			LabelSelector: operatorLabelSelector,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list operator pods")
+		}
+
+		for _, p := range list.Items {
+			names = append(names, p.GetName())
+		}
+		return names, nil
+	}
+
+	deployments, err := pm.targetDeployments(namespace, target)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, deployment := range deployments {
+		members := deploymentGroupMembers(&deployment, target.Group)
+
+		if target.IsLeader {
+			leader, err := getAgencyLeader(ctx, pm.client, namespace, &deployment)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get agency leader of %s", deployment.GetName())
+			}
+			members = filterMembers(members, func(m arangoapi.MemberStatus) bool {
+				return m.ID == leader
+			})
+		}
+
+		for _, member := range members {
+			if member.PodName != "" {
+				names = append(names, member.PodName)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// targetDeployments returns the deployments of the namespace a target applies to
+func (pm *podManager) targetDeployments(namespace string, target PodTarget) ([]arangoapi.ArangoDeployment, error) {
+	if target.Deployment != "" {
+		if !pm.selector.AllowsDeployment(target.Deployment) {
+			return nil, errors.Errorf("deployment %s is not allowed by the target selector", target.Deployment)
+		}
+
+		deployment, err := pm.arango.ArangoDeployments(namespace).Get(target.Deployment, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && target.Namespace == "" {
+			return nil, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to get deployment")
+		}
+		return []arangoapi.ArangoDeployment{*deployment}, nil
+	}
+
+	list, err := pm.arango.ArangoDeployments(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list deployments")
+	}
+
+	var deployments []arangoapi.ArangoDeployment
+	for _, deployment := range list.Items {
+		if pm.selector.AllowsDeployment(deployment.GetName()) {
+			deployments = append(deployments, deployment)
+		}
+	}
+
+	return deployments, nil
+}
+
+// deploymentGroupMembers returns the members of the deployment belonging to the pod group
+func deploymentGroupMembers(deployment *arangoapi.ArangoDeployment, group PodGroup) arangoapi.MemberStatusList {
+	switch group {
+	case PodGroupAgent:
+		return deployment.Status.Members.Agents
+	case PodGroupCoordinator:
+		return deployment.Status.Members.Coordinators
+	case PodGroupDBServer:
+		return deployment.Status.Members.DBServers
+	}
+	return nil
+}
+
+func filterMembers(members arangoapi.MemberStatusList, predicate func(arangoapi.MemberStatus) bool) arangoapi.MemberStatusList {
+	var result arangoapi.MemberStatusList
+	for _, m := range members {
+		if predicate(m) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+func (p *pod) Name() string {
+	return p.name
+}
+
+func (p *pod) Namespace() string {
+	return p.namespace
+}
+
+func (p *pod) Evict(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions, eviction EvictionOptions) error {
+	go func() {
+		result, err := evictPod(ctx, p.manager.client, p.name, p.namespace, options, eviction)
+		if result.Blocked > 0 {
+			log.Printf("Eviction of Pod %s/%s was %s", p.namespace, p.name, result.String())
+		}
+		completion <- err
+	}()
+	return nil
+}
+
+func (p *pod) Delete(ctx context.Context, completion chan<- error, options *metav1.DeleteOptions) error {
+	go func() {
+		completion <- deletePod(ctx, p.manager.client, p.namespace, p.name, options)
+	}()
+	return nil
+}
+
+func (p *pod) DeletePersistentVolumeClaims(ctx context.Context, completion chan<- error, removeFinalizer bool, options *metav1.DeleteOptions) error {
+	obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get pod")
+	}
+
+	var watchers []watch.Interface
+	for _, volume := range obj.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		watcher, err := deletePVC(p.manager.client, p.namespace, volume.PersistentVolumeClaim.ClaimName, removeFinalizer, options)
+		if err != nil {
+			for _, w := range watchers {
+				w.Stop()
+			}
+			return err
+		}
+
+		if watcher != nil {
+			watchers = append(watchers, watcher)
+		}
+	}
+
+	go func() {
+		var result error
+		for _, w := range watchers {
+			if err := waitForDeleted(ctx, w); err != nil && result == nil {
+				result = errors.Wrap(err, "failed to wait for pvc deletion")
+			}
+		}
+		if result == nil {
+			log.Printf("PVCs of %s/%s deleted", p.namespace, p.name)
+		}
+		completion <- result
+	}()
+
+	return nil
+}
+
+func (p *pod) Isolate(ctx context.Context, peers []string) error {
+	return partitionPod(p.manager.client, p.namespace, p.name, peers)
+}
+
+func (p *pod) Reconnect(ctx context.Context) error {
+	return healPartition(p.manager.client, p.namespace, p.name)
+}
+
+func (p *pod) Netem(ctx context.Context, options NetemOptions) error {
+	if options.HelperImage != "" {
+		return startHelper(ctx, p.manager.client, p.namespace, p.name, netemHelper(options))
+	}
+	return addNetem(p.manager.config, p.manager.client, p.namespace, p.name, options)
+}
+
+func (p *pod) ClearNetem(ctx context.Context, options NetemOptions) error {
+	if options.HelperImage != "" {
+		return stopHelper(ctx, p.manager.client, p.namespace, p.name, netemHelper(options).Kind)
+	}
+	return removeNetem(p.manager.config, p.manager.client, p.namespace, p.name)
+}
+
+func (p *pod) Freeze(ctx context.Context, image string, deadline time.Duration) error {
+	return startHelper(ctx, p.manager.client, p.namespace, p.name, freezeHelper(image, deadline))
+}
+
+func (p *pod) Thaw(ctx context.Context) error {
+	return stopHelper(ctx, p.manager.client, p.namespace, p.name, freezeHelper("", 0).Kind)
+}
+
+func (p *pod) Signal(ctx context.Context, signal ProcessSignal, helperImage string) error {
+	if signal == SignalKill {
+		options := killHelper(helperImage)
+		if err := startHelper(ctx, p.manager.client, p.namespace, p.name, options); err != nil {
+			return err
+		}
+		return stopHelper(ctx, p.manager.client, p.namespace, p.name, options.Kind)
+	}
+
+	command := []string{"kill", "-" + string(signal), "1"}
+	if _, err := execInPod(p.manager.config, p.manager.client, p.namespace, p.name, k8sutil.ServerContainerName, command); err != nil {
+		return err
+	}
+
+	log.Printf("Sent SIG%s to pod %s/%s", signal, p.namespace, p.name)
+	return nil
+}
+
+func (p *pod) RestartCount() (int32, bool, error) {
+	obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		return 0, false, errors.Wrap(err, "failed to get pod")
+	}
+
+	return containerRestarts(obj, k8sutil.ServerContainerName)
+}
+
+func (p *pod) FillVolume(ctx context.Context, percent int) error {
+	return fillVolume(p.manager.config, p.manager.client, p.namespace, p.name, percent)
+}
+
+func (p *pod) FreeVolume(ctx context.Context) error {
+	return freeVolume(ctx, p.manager.config, p.manager.client, p.namespace, p.name)
+}
+
+func (p *pod) UID() (types.UID, error) {
+	obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get pod")
+	}
+
+	return obj.GetUID(), nil
+}
+
+func (p *pod) WaitForReplacement(ctx context.Context, uid types.UID) error {
+	err := retry(ctx, func() error {
+		obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if obj.GetUID() == uid {
+			return fmt.Errorf("pod %s is not replaced yet", p.name)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "pod %s was not replaced", p.name)
+	}
+
+	log.Printf("Pod %s/%s was replaced", p.namespace, p.name)
+	return nil
+}
+
+func (p *pod) Deployment() (string, error) {
+	obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get pod")
+	}
+
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.Kind != "ArangoDeployment" {
+		return "", fmt.Errorf("pod %s does not belong to an ArangoDeployment", p.name)
+	}
+
+	return owner.Name, nil
+}
+
+func (p *pod) Node() (Node, error) {
+	obj, err := p.manager.client.CoreV1().Pods(p.namespace).Get(p.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get pod")
+	}
+
+	if obj.Spec.NodeName == "" {
+		return nil, fmt.Errorf("pod %s is not scheduled", p.name)
+	}
+
+	return p.manager.nodes.Node(obj.Spec.NodeName), nil
+}
+
+// containsString returns true if the list contains s
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isPodReady returns true if the PodReady condition of the pod is true
+func isPodReady(pod *v1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// defaultEvictionTimeout limits the retries of a blocked EvictPod action
+// without timeout
+const defaultEvictionTimeout = 5 * time.Minute
+
+// EvictionOptions control how an eviction blocked by a PodDisruptionBudget is handled
+type EvictionOptions struct {
+	// Timeout is the maximum time a blocked eviction is retried, zero retries
+	// until the context is done
+	Timeout time.Duration
+	// ForceDelete deletes the pod without grace period once the timeout is reached
+	ForceDelete bool
+}
+
+// EvictionResult records how long an eviction was blocked by
+// PodDisruptionBudgets and whether the pod was force deleted in the end
+type EvictionResult struct {
+	Blocked time.Duration
+	Budgets []string
+	Forced  bool
+}
+
+// String describes the blocking of the eviction, empty if it was never blocked
+func (r EvictionResult) String() string {
+	if r.Blocked == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("blocked for %s by PodDisruptionBudget %s", r.Blocked.Round(time.Second), strings.Join(r.Budgets, ","))
+	if r.Forced {
+		text += ", force deleted"
+	}
+	return text
+}
+
+// EvictionBlockedError is returned if an eviction was blocked until the timeout
+type EvictionBlockedError struct {
+	Namespace string
+	Pod       string
+	Duration  time.Duration
+	Budgets   []string
+}
+
+func (e *EvictionBlockedError) Error() string {
+	return fmt.Sprintf("eviction of %s/%s blocked for %s by PodDisruptionBudget %s",
+		e.Namespace, e.Pod, e.Duration.Round(time.Second), strings.Join(e.Budgets, ","))
+}
+
+// blockingDisruptionBudgets returns the names of all PDBs selecting the given pod
+func blockingDisruptionBudgets(client k8s.Interface, namespace, name string) ([]string, error) {
+	pod, err := client.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.PolicyV1beta1().PodDisruptionBudgets(namespace).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, pdb := range list.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil {
+			continue
+		}
+		if !selector.Empty() && selector.Matches(labels.Set(pod.GetLabels())) {
+			names = append(names, pdb.GetName())
+		}
+	}
+
+	return names, nil
 }
 
 // evictPod creates a Eviction resource for the given pod and waits for the pod to be deleted
-// Ignores if pod is not found
-func evictPod(ctx context.Context, client k8s.Interface, name, namespace string, options *metav1.DeleteOptions) error {
+// Ignores if pod is not found. The result records whether the eviction was
+// blocked, also if it failed.
+func evictPod(ctx context.Context, client k8s.Interface, name, namespace string, options *metav1.DeleteOptions, evictionOptions EvictionOptions) (EvictionResult, error) {
 
 	const (
 		EvictionKind       = "Eviction"
@@ -70,31 +659,72 @@ func evictPod(ctx context.Context, client k8s.Interface, name, namespace string,
 		DeleteOptions: options,
 	}
 
+	var result EvictionResult
+
 	// Receive events for the Pod before evicting it
 	watcher, err := client.CoreV1().Pods(namespace).Watch(metav1.ListOptions{
 		FieldSelector: fields.OneTermEqualSelector(api.ObjectNameField, name).String(),
 	})
 	if apierrors.IsNotFound(err) {
-		return nil
+		return result, nil
 	} else if err != nil {
-		return errors.Wrap(err, "failed to watch pod")
+		return result, errors.Wrap(err, "failed to watch pod")
 	}
 	defer watcher.Stop()
 
+	var blockedSince time.Time
+
 	// try multiple times to evict the pod
 	for {
-		if err := client.CoreV1().Pods(namespace).Evict(eviction); err == nil {
+		err := client.CoreV1().Pods(namespace).Evict(eviction)
+		if !blockedSince.IsZero() {
+			result.Blocked = time.Since(blockedSince)
+		}
+
+		if err == nil {
 			log.Printf("Created Eviction for Pod %s/%s", namespace, name)
 			break
 		} else if apierrors.IsNotFound(err) {
-			return nil
+			return result, nil
 		} else if !apierrors.IsTooManyRequests(err) {
-			return err
+			return result, err
+		}
+
+		if blockedSince.IsZero() {
+			blockedSince = time.Now()
+			if result.Budgets, err = blockingDisruptionBudgets(client, namespace, name); err != nil {
+				log.Printf("Failed to determine PodDisruptionBudgets of %s/%s: %s", namespace, name, err.Error())
+			}
+			log.Printf("Eviction of Pod %s/%s blocked by PodDisruptionBudget %s", namespace, name, strings.Join(result.Budgets, ","))
+		}
+
+		if evictionOptions.Timeout > 0 && result.Blocked >= evictionOptions.Timeout {
+			blocked := &EvictionBlockedError{
+				Namespace: namespace,
+				Pod:       name,
+				Duration:  result.Blocked,
+				Budgets:   result.Budgets,
+			}
+			log.Println(blocked.Error())
+
+			if !evictionOptions.ForceDelete {
+				return result, blocked
+			}
+
+			gracePeriod := int64(0)
+			log.Printf("Force deleting Pod %s/%s", namespace, name)
+			result.Forced = true
+			if err := client.CoreV1().Pods(namespace).Delete(name, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); apierrors.IsNotFound(err) {
+				return result, nil
+			} else if err != nil {
+				return result, err
+			}
+			break
 		}
 
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return result, ctx.Err()
 		case <-time.After(2 * time.Second):
 		}
 	}
@@ -103,14 +733,14 @@ func evictPod(ctx context.Context, client k8s.Interface, name, namespace string,
 		select {
 		case ev, ok := <-watcher.ResultChan():
 			if !ok {
-				return errors.New("watch channel closed")
+				return result, errors.New("watch channel closed")
 			}
 			if ev.Type == watch.Deleted {
 				log.Printf("%s/%s evicted", namespace, name)
-				return nil
+				return result, nil
 			}
 		case <-ctx.Done():
-			return ctx.Err()
+			return result, ctx.Err()
 		}
 	}
 }
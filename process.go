@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+)
+
+// ProcessSignal is the name of a signal without the SIG prefix
+type ProcessSignal string
+
+const (
+	SignalTerm ProcessSignal = "TERM"
+	SignalInt  ProcessSignal = "INT"
+	SignalKill ProcessSignal = "KILL"
+)
+
+// killHelperDeadline limits the lifetime of the helpers sending SIGKILL
+const killHelperDeadline = time.Minute
+
+// freezeHelper returns the helper stopping all processes of the container
+// with SIGSTOP and continuing them with SIGCONT. arangod runs as PID 1 of
+// its container, which ignores SIGSTOP sent from inside the container, so
+// the signals are sent from the host PID namespace.
+func freezeHelper(image string, deadline time.Duration) helperOptions {
+	if image == "" {
+		image = defaultHelperImage
+	}
+	return helperOptions{
+		Kind:     "freeze",
+		Image:    image,
+		Apply:    "kill -STOP $pids",
+		Revert:   "kill -CONT $pids",
+		Deadline: deadline,
+	}
+}
+
+// killHelper returns the helper killing all processes of the container.
+// Like SIGSTOP, SIGKILL is ignored by PID 1 if sent from inside the container.
+func killHelper(image string) helperOptions {
+	if image == "" {
+		image = defaultHelperImage
+	}
+	return helperOptions{
+		Kind:     "kill",
+		Image:    image,
+		Apply:    "kill -KILL $pids",
+		Revert:   ":",
+		Deadline: killHelperDeadline,
+	}
+}
+
+// containerRestarts returns the restart count of the container of the pod
+// and whether it is running
+func containerRestarts(pod *v1.Pod, container string) (int32, bool, error) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container {
+			return status.RestartCount, status.State.Running != nil, nil
+		}
+	}
+	return 0, false, errors.Errorf("pod %s has no container %s", pod.GetName(), container)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fields "k8s.io/apimachinery/pkg/fields"
+	watch "k8s.io/apimachinery/pkg/watch"
+	k8s "k8s.io/client-go/kubernetes"
+	k8sretry "k8s.io/client-go/util/retry"
+	api "k8s.io/kubernetes/pkg/apis/core"
+)
+
+// pvcProtectionFinalizer prevents the deletion of PVCs still used by a pod
+const pvcProtectionFinalizer = "kubernetes.io/pvc-protection"
+
+// removePVCProtection removes the pvc-protection finalizer of the given PVC.
+// Ignores if the PVC is not found.
+func removePVCProtection(client k8s.Interface, namespace, name string) error {
+	return k8sretry.RetryOnConflict(k8sretry.DefaultRetry, func() error {
+		pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var finalizers []string
+		for _, f := range pvc.GetFinalizers() {
+			if f != pvcProtectionFinalizer {
+				finalizers = append(finalizers, f)
+			}
+		}
+
+		if len(finalizers) == len(pvc.GetFinalizers()) {
+			return nil
+		}
+
+		log.Printf("Removing %s finalizer from PVC %s/%s", pvcProtectionFinalizer, namespace, name)
+		pvc.SetFinalizers(finalizers)
+		_, err = client.CoreV1().PersistentVolumeClaims(namespace).Update(pvc)
+		return err
+	})
+}
+
+// deletePVC deletes the given PVC and returns a watcher receiving its
+// events. Returns a nil watcher if the PVC is not found.
+func deletePVC(client k8s.Interface, namespace, name string, removeFinalizer bool, options *metav1.DeleteOptions) (watch.Interface, error) {
+
+	// Receive events for the PVC before deleting it
+	watcher, err := client.CoreV1().PersistentVolumeClaims(namespace).Watch(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector(api.ObjectNameField, name).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to watch pvc")
+	}
+
+	log.Printf("Deleting PVC %s/%s", namespace, name)
+	if err := client.CoreV1().PersistentVolumeClaims(namespace).Delete(name, options); apierrors.IsNotFound(err) {
+		watcher.Stop()
+		return nil, nil
+	} else if err != nil {
+		watcher.Stop()
+		return nil, errors.Wrap(err, "failed to delete pvc")
+	}
+
+	if removeFinalizer {
+		if err := removePVCProtection(client, namespace, name); err != nil {
+			watcher.Stop()
+			return nil, errors.Wrap(err, "failed to remove pvc finalizer")
+		}
+	}
+
+	return watcher, nil
+}
+
+// waitForDeleted waits until the watcher reports the deletion of its object and stops it
+func waitForDeleted(ctx context.Context, watcher watch.Interface) error {
+	defer watcher.Stop()
+
+	for {
+		select {
+		case ev, ok := <-watcher.ResultChan():
+			if !ok {
+				return errors.New("watch channel closed")
+			}
+			if ev.Type == watch.Deleted {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// replayRecorder writes the actions of a random chaos run into an action
+// script, so that the run can be repeated using -script. A nil recorder
+// records nothing.
+type replayRecorder struct {
+	path   string
+	script ActionScript
+}
+
+// newReplayRecorder creates a recorder writing to the given file
+func newReplayRecorder(path string, seed int64) (*replayRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, errors.Wrap(err, "failed to create replay directory")
+	}
+
+	r := &replayRecorder{
+		path:   path,
+		script: ActionScript{Seed: seed},
+	}
+	return r, r.Update(nil)
+}
+
+// Update rewrites the replay file with all committed actions followed by
+// the actions of the current round. The file is complete even if the
+// process dies in the middle of a round.
+func (r *replayRecorder) Update(current ActionDescriptionList) error {
+	if r == nil {
+		return nil
+	}
+
+	script := r.script
+	script.Actions = append(append(ActionDescriptionList{}, r.script.Actions...), current...)
+
+	data, err := json.MarshalIndent(script, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode replay")
+	}
+
+	tmp := r.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return errors.Wrap(err, "failed to write replay")
+	}
+	return errors.Wrap(os.Rename(tmp, r.path), "failed to write replay")
+}
+
+// Commit appends the actions of a completed round
+func (r *replayRecorder) Commit(actions ActionDescriptionList) error {
+	if r == nil {
+		return nil
+	}
+
+	r.script.Actions = append(r.script.Actions, actions...)
+	return r.Update(nil)
+}
+
+// chaosRound collects the actions of one round of random chaos
+type chaosRound struct {
+	// delay is the time waited before the first chaos of the round
+	delay    time.Duration
+	branches ActionDescriptionList
+	cleanups ActionDescriptionList
+	// healthy is the number of cleanups run before the cluster became
+	// healthy again, -1 while still waiting
+	healthy int
+}
+
+func newChaosRound() *chaosRound {
+	return &chaosRound{healthy: -1}
+}
+
+// Delay records the time waited before the first chaos of the round
+func (r *chaosRound) Delay(delay time.Duration) {
+	r.delay = delay
+}
+
+// AddChaos records a chaos action started at the given offset from the
+// start of the round
+func (r *chaosRound) AddChaos(offset time.Duration, chaos ActionDescription) {
+	if offset > 0 {
+		chaos = ActionDescription{
+			Type: ActionTypeSequence,
+			Sequence: &ActionSequenceDescription{
+				Actions: ActionDescriptionList{
+					{Type: ActionTypeWait, Wait: &ActionWaitDescription{Duration: Duration(offset)}},
+					chaos,
+				},
+			},
+		}
+	}
+	r.branches = append(r.branches, chaos)
+}
+
+// AddCleanup records a cleanup action
+func (r *chaosRound) AddCleanup(cleanup ActionDescription) {
+	r.cleanups = append(r.cleanups, cleanup)
+}
+
+// Healthy records that the cluster became healthy after the cleanups so far
+func (r *chaosRound) Healthy() {
+	r.healthy = len(r.cleanups)
+}
+
+// Actions returns the round as actions of a script
+func (r *chaosRound) Actions() ActionDescriptionList {
+	var list ActionDescriptionList
+	if r.delay > 0 {
+		list = append(list, ActionDescription{
+			Type: ActionTypeWait,
+			Wait: &ActionWaitDescription{Duration: Duration(r.delay)},
+		})
+	}
+
+	switch len(r.branches) {
+	case 0:
+	case 1:
+		list = append(list, r.branches[0])
+	default:
+		list = append(list, ActionDescription{
+			Type:     ActionTypeParallel,
+			Parallel: &ActionParallelDescription{Actions: r.branches},
+		})
+	}
+	chaos := len(list)
+
+	list = append(list, r.cleanups...)
+	if r.healthy >= 0 {
+		if i := chaos + r.healthy - 1; i >= 0 {
+			list[i].WaitForHealth = true
+		}
+	}
+
+	return list
+}
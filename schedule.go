@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scheduler decides the time between two faults of the random chaos
+type Scheduler interface {
+	// Next returns the time to wait before the next fault
+	Next() time.Duration
+}
+
+// fixedScheduler starts faults at a fixed interval
+type fixedScheduler struct {
+	interval time.Duration
+}
+
+func (s *fixedScheduler) Next() time.Duration {
+	return s.interval
+}
+
+// uniformScheduler waits a uniformly distributed time below max
+type uniformScheduler struct {
+	max time.Duration
+}
+
+func (s *uniformScheduler) Next() time.Duration {
+	return time.Duration(rand.Int63n(int64(s.max)))
+}
+
+// poissonScheduler starts faults as a Poisson process, so the times
+// between faults are exponentially distributed around the mean
+type poissonScheduler struct {
+	mean time.Duration
+}
+
+func (s *poissonScheduler) Next() time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(s.mean))
+}
+
+var schedulers = map[string]func(d time.Duration) Scheduler{
+	"fixed":   func(d time.Duration) Scheduler { return &fixedScheduler{interval: d} },
+	"uniform": func(d time.Duration) Scheduler { return &uniformScheduler{max: d} },
+	"poisson": func(d time.Duration) Scheduler { return &poissonScheduler{mean: d} },
+}
+
+// SchedulerNames returns the names of all schedulers
+func SchedulerNames() []string {
+	var names []string
+	for name := range schedulers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewScheduler creates a scheduler from a specification like "poisson:5m"
+func NewScheduler(spec string) (Scheduler, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid schedule %q, expected <scheduler>:<duration>", spec)
+	}
+
+	factory, ok := schedulers[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler %q, known are %v", parts[0], SchedulerNames())
+	}
+
+	d, err := time.ParseDuration(parts[1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule %q", spec)
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("invalid schedule %q, duration must be positive", spec)
+	}
+
+	return factory(d), nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActiveWindow is a daily time range during which chaos may start. A
+// window ending before its start spans midnight, e.g. 22:00-06:00.
+type ActiveWindow struct {
+	Days  [7]bool
+	Start time.Duration
+	End   time.Duration
+}
+
+type ActiveWindows []ActiveWindow
+
+// ParseActiveWindows parses windows like "Mon-Fri 09:00-17:00; Sat,Sun 10:00-12:00".
+// The days are optional and default to every day.
+func ParseActiveWindows(spec string) (ActiveWindows, error) {
+	var windows ActiveWindows
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		window, err := parseActiveWindow(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid active window %q", part)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+func parseActiveWindow(spec string) (ActiveWindow, error) {
+	var window ActiveWindow
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		for i := range window.Days {
+			window.Days[i] = true
+		}
+	case 2:
+		if err := parseWeekdays(fields[0], &window.Days); err != nil {
+			return window, err
+		}
+		fields = fields[1:]
+	default:
+		return window, errors.New("expected [days] <start>-<end>")
+	}
+
+	times := strings.Split(fields[0], "-")
+	if len(times) != 2 {
+		return window, errors.New("expected <start>-<end>")
+	}
+
+	var err error
+	if window.Start, err = parseTimeOfDay(times[0]); err != nil {
+		return window, err
+	}
+	if window.End, err = parseTimeOfDay(times[1]); err != nil {
+		return window, err
+	}
+	if window.Start == window.End {
+		return window, errors.New("start and end must differ")
+	}
+
+	return window, nil
+}
+
+// parseWeekdays parses days like "Mon-Fri" or "Sat,Sun" or "*"
+func parseWeekdays(spec string, days *[7]bool) error {
+	for _, item := range strings.Split(spec, ",") {
+		if item == "*" {
+			for i := range days {
+				days[i] = true
+			}
+			continue
+		}
+
+		bounds := strings.Split(item, "-")
+		if len(bounds) > 2 {
+			return fmt.Errorf("invalid days %q", item)
+		}
+
+		var parsed []time.Weekday
+		for _, name := range bounds {
+			day, ok := weekdays[strings.ToLower(name)]
+			if !ok {
+				return fmt.Errorf("unknown day %q", name)
+			}
+			parsed = append(parsed, day)
+		}
+
+		day, last := parsed[0], parsed[len(parsed)-1]
+		for {
+			days[day] = true
+			if day == last {
+				break
+			}
+			day = (day + 1) % 7
+		}
+	}
+	return nil
+}
+
+// parseTimeOfDay parses HH:MM into the time since midnight
+func parseTimeOfDay(spec string) (time.Duration, error) {
+	t, err := time.Parse("15:04", spec)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", spec)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// midnight returns the start of the day of t
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Active returns true if t is inside the window
+func (w ActiveWindow) Active(t time.Time) bool {
+	day := midnight(t)
+	timeOfDay := t.Sub(day)
+
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && timeOfDay >= w.Start && timeOfDay < w.End
+	}
+
+	// The window spans midnight, it started either today or yesterday
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.Days[t.Weekday()] && timeOfDay >= w.Start) || (w.Days[yesterday] && timeOfDay < w.End)
+}
+
+// Active returns true if there are no windows or t is inside one of them
+func (windows ActiveWindows) Active(t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	for _, w := range windows {
+		if w.Active(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the first time at or after t inside one of the windows
+func (windows ActiveWindows) Next(t time.Time) time.Time {
+	if windows.Active(t) {
+		return t
+	}
+
+	var next time.Time
+	day := midnight(t)
+	for i := 0; i <= 7; i++ {
+		start := day.AddDate(0, 0, i)
+		for _, w := range windows {
+			candidate := start.Add(w.Start)
+			if !w.Days[candidate.Weekday()] || !candidate.After(t) {
+				continue
+			}
+			if next.IsZero() || candidate.Before(next) {
+				next = candidate
+			}
+		}
+	}
+	return next
+}
+
+// ChaosSchedule decides when the faults of the random chaos start
+type ChaosSchedule struct {
+	Scheduler Scheduler
+	Windows   ActiveWindows
+	// Duration is the total duration of the run, unlimited if zero
+	Duration time.Duration
+
+	deadline  time.Time
+	started   bool
+	scheduled bool
+}
+
+// Start starts the run at now and returns its deadline, zero if the
+// duration is unlimited. Wait starts the run if it is not started yet.
+func (s *ChaosSchedule) Start(now time.Time) time.Time {
+	s.started = true
+	if s.Duration > 0 {
+		s.deadline = now.Add(s.Duration)
+	}
+	return s.deadline
+}
+
+// Wait waits until the next fault is due and returns the time waited. The
+// first fault is due immediately. It returns false once the run is over.
+func (s *ChaosSchedule) Wait(ctx context.Context, env ActionInterface) (time.Duration, bool, error) {
+	now := env.Now()
+	if !s.started {
+		s.Start(now)
+	}
+
+	next := now
+	if s.scheduled {
+		next = now.Add(s.Scheduler.Next())
+	}
+	s.scheduled = true
+
+	if active := s.Windows.Next(next); !active.Equal(next) {
+		if active.IsZero() {
+			log.Printf("No active window found")
+			return 0, false, nil
+		}
+		log.Printf("Outside of active windows, waiting until %s", active.Format(time.RFC1123))
+		next = active
+	}
+
+	if !s.deadline.IsZero() && next.After(s.deadline) {
+		log.Printf("Chaos duration of %s reached", s.Duration)
+		return 0, false, nil
+	}
+
+	delay := next.Sub(now)
+	if delay > 0 {
+		log.Printf("Waiting %s until next chaos", delay.Round(time.Second))
+		if err := env.Sleep(ctx, delay); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return delay, true, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseActiveWindows(t *testing.T) {
+	everyDay := [7]bool{true, true, true, true, true, true, true}
+
+	tests := []struct {
+		spec    string
+		windows ActiveWindows
+		err     bool
+	}{
+		{spec: ""},
+		{
+			spec: "Mon-Fri 09:00-17:00; Sat,Sun 10:00-12:00",
+			windows: ActiveWindows{
+				{Days: [7]bool{false, true, true, true, true, true, false}, Start: 9 * time.Hour, End: 17 * time.Hour},
+				{Days: [7]bool{true, false, false, false, false, false, true}, Start: 10 * time.Hour, End: 12 * time.Hour},
+			},
+		},
+		{
+			spec:    "22:00-06:30",
+			windows: ActiveWindows{{Days: everyDay, Start: 22 * time.Hour, End: 6*time.Hour + 30*time.Minute}},
+		},
+		{
+			spec:    "fri-mon 08:00-09:00",
+			windows: ActiveWindows{{Days: [7]bool{true, true, false, false, false, true, true}, Start: 8 * time.Hour, End: 9 * time.Hour}},
+		},
+		{
+			spec:    "* 08:00-09:00",
+			windows: ActiveWindows{{Days: everyDay, Start: 8 * time.Hour, End: 9 * time.Hour}},
+		},
+		{spec: "Mon-Fri", err: true},
+		{spec: "Mon 9-17", err: true},
+		{spec: "Mon 09:00", err: true},
+		{spec: "Funday 09:00-10:00", err: true},
+		{spec: "Mon-Tue-Wed 09:00-10:00", err: true},
+		{spec: "10:00-10:00", err: true},
+		{spec: "Mon 09:00-10:00 extra", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			windows, err := ParseActiveWindows(test.spec)
+			if test.err {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", windows)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(windows) != len(test.windows) {
+				t.Fatalf("expected %d windows, got %+v", len(test.windows), windows)
+			}
+			for i := range windows {
+				if windows[i] != test.windows[i] {
+					t.Errorf("window %d: expected %+v, got %+v", i, test.windows[i], windows[i])
+				}
+			}
+		})
+	}
+}
+
+func TestActiveWindowsNext(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.January, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		t    time.Time
+		next time.Time
+	}{
+		{name: "no windows", spec: "", t: at(6, 3, 0), next: at(6, 3, 0)},
+		{name: "inside", spec: "Mon-Fri 09:00-17:00", t: at(1, 10, 0), next: at(1, 10, 0)},
+		{name: "before start", spec: "Mon-Fri 09:00-17:00", t: at(1, 8, 0), next: at(1, 9, 0)},
+		{name: "at end", spec: "Mon-Fri 09:00-17:00", t: at(1, 17, 0), next: at(2, 9, 0)},
+		{name: "weekend", spec: "Mon-Fri 09:00-17:00", t: at(5, 18, 0), next: at(8, 9, 0)},
+		{name: "earliest window", spec: "Mon 12:00-13:00; Mon 10:00-11:00", t: at(1, 9, 0), next: at(1, 10, 0)},
+		{name: "over midnight", spec: "Sat 22:00-02:00", t: at(7, 1, 0), next: at(7, 1, 0)},
+		{name: "after midnight window", spec: "Sat 22:00-02:00", t: at(7, 3, 0), next: at(13, 22, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			windows, err := ParseActiveWindows(test.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if next := windows.Next(test.t); !next.Equal(test.next) {
+				t.Errorf("expected %s, got %s", test.next, next)
+			}
+		})
+	}
+}
+
+// clockEnvironment is an environment whose Sleep only advances its clock
+type clockEnvironment struct {
+	ActionInterface
+	now time.Time
+}
+
+func (e *clockEnvironment) Now() time.Time {
+	return e.now
+}
+
+func (e *clockEnvironment) Sleep(ctx context.Context, d time.Duration) error {
+	e.now = e.now.Add(d)
+	return nil
+}
+
+func TestChaosScheduleWait(t *testing.T) {
+	env := &clockEnvironment{now: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)}
+	schedule := ChaosSchedule{
+		Scheduler: &fixedScheduler{interval: 10 * time.Minute},
+		Duration:  25 * time.Minute,
+	}
+
+	if deadline := schedule.Start(env.Now()); !deadline.Equal(env.now.Add(25 * time.Minute)) {
+		t.Fatalf("unexpected deadline %s", deadline)
+	}
+
+	for i, expected := range []time.Duration{0, 10 * time.Minute, 10 * time.Minute} {
+		delay, ok, err := schedule.Wait(context.Background(), env)
+		if err != nil || !ok {
+			t.Fatalf("wait %d: unexpected result %t, %v", i, ok, err)
+		}
+		if delay != expected {
+			t.Errorf("wait %d: expected delay %s, got %s", i, expected, delay)
+		}
+	}
+
+	if _, ok, err := schedule.Wait(context.Background(), env); ok || err != nil {
+		t.Errorf("expected the run to end after its duration, got %t, %v", ok, err)
+	}
+}
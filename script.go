@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadActionScript reads an action script from a JSON or YAML file
+func LoadActionScript(path string) (*ActionScript, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read script")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		data, err = yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse script")
+		}
+	}
+
+	var script ActionScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		return nil, errors.Wrap(err, "failed to parse script")
+	}
+
+	return &script, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+
+	k8sutil "github.com/arangodb/kube-arangodb/pkg/util/k8sutil"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TargetSelector restricts the pods, nodes and deployments chaos may
+// target. The zero value allows everything.
+type TargetSelector struct {
+	// PodSelector selects the pods that may be deleted or evicted
+	PodSelector labels.Selector
+	// NodeSelector selects the nodes that may be drained or killed
+	NodeSelector labels.Selector
+	// Deployments lists the ArangoDeployments that may be targeted, all
+	// deployments if empty
+	Deployments []string
+	// Exclude lists names of pods, nodes and deployments never targeted
+	Exclude []string
+}
+
+// NewTargetSelector creates a selector from label selector strings and
+// comma separated lists of names
+func NewTargetSelector(podSelector, nodeSelector, deployments, exclude string) (TargetSelector, error) {
+	var selector TargetSelector
+	var err error
+
+	if selector.PodSelector, err = labels.Parse(podSelector); err != nil {
+		return selector, errors.Wrap(err, "invalid pod selector")
+	}
+	if selector.NodeSelector, err = labels.Parse(nodeSelector); err != nil {
+		return selector, errors.Wrap(err, "invalid node selector")
+	}
+
+	selector.Deployments = splitNames(deployments)
+	selector.Exclude = splitNames(exclude)
+	return selector, nil
+}
+
+// splitNames splits a comma separated list, ignoring empty elements
+func splitNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (s TargetSelector) excluded(name string) bool {
+	for _, e := range s.Exclude {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// podLabelSelector returns the label selector for listing pods
+func (s TargetSelector) podLabelSelector() string {
+	if s.PodSelector == nil {
+		return ""
+	}
+	return s.PodSelector.String()
+}
+
+// nodeLabelSelector returns the label selector for listing nodes
+func (s TargetSelector) nodeLabelSelector() string {
+	if s.NodeSelector == nil {
+		return ""
+	}
+	return s.NodeSelector.String()
+}
+
+// AllowsDeployment returns true if the deployment may be targeted
+func (s TargetSelector) AllowsDeployment(name string) bool {
+	if s.excluded(name) {
+		return false
+	}
+	if len(s.Deployments) == 0 {
+		return true
+	}
+	for _, d := range s.Deployments {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsPod returns true if the pod may be targeted
+func (s TargetSelector) AllowsPod(pod *v1.Pod) bool {
+	if s.excluded(pod.GetName()) {
+		return false
+	}
+	if deployment, ok := pod.GetLabels()[k8sutil.LabelKeyArangoDeployment]; ok && !s.AllowsDeployment(deployment) {
+		return false
+	}
+	return s.PodSelector == nil || s.PodSelector.Matches(labels.Set(pod.GetLabels()))
+}
+
+// AllowsNode returns true if the node may be targeted
+func (s TargetSelector) AllowsNode(node *v1.Node) bool {
+	if s.excluded(node.GetName()) {
+		return false
+	}
+	return s.NodeSelector == nil || s.NodeSelector.Matches(labels.Set(node.GetLabels()))
+}
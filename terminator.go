@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s "k8s.io/client-go/kubernetes"
+	k8sretry "k8s.io/client-go/util/retry"
+)
+
+// NodeTerminator kills nodes of the cluster. Implementations decide how a
+// node is actually lost, e.g. by simulating it within kubernetes or by
+// stopping the machine using the API of a cloud provider.
+type NodeTerminator interface {
+	// Terminate kills the given node
+	Terminate(ctx context.Context, name string) error
+	// Restore brings a terminated node back, if the provider supports it
+	Restore(ctx context.Context, name string) error
+}
+
+// NodeTerminatorOptions are passed to the node terminator factories
+type NodeTerminatorOptions struct {
+	// DeleteNode also deletes the Node object when simulating a crash
+	DeleteNode bool
+}
+
+// NodeTerminatorFactory creates a node terminator
+type NodeTerminatorFactory func(client k8s.Interface, options NodeTerminatorOptions) (NodeTerminator, error)
+
+var (
+	nodeTerminatorsMutex sync.Mutex
+	nodeTerminators      = map[string]NodeTerminatorFactory{
+		"simulate": newSimulateNodeTerminator,
+	}
+)
+
+// RegisterNodeTerminator makes a node terminator provider available under the given name
+func RegisterNodeTerminator(name string, factory NodeTerminatorFactory) {
+	nodeTerminatorsMutex.Lock()
+	defer nodeTerminatorsMutex.Unlock()
+	nodeTerminators[name] = factory
+}
+
+// NodeTerminatorNames returns the names of all registered providers
+func NodeTerminatorNames() []string {
+	nodeTerminatorsMutex.Lock()
+	defer nodeTerminatorsMutex.Unlock()
+
+	var names []string
+	for name := range nodeTerminators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewNodeTerminator creates the node terminator registered under the given name
+func NewNodeTerminator(name string, client k8s.Interface, options NodeTerminatorOptions) (NodeTerminator, error) {
+	nodeTerminatorsMutex.Lock()
+	factory, ok := nodeTerminators[name]
+	nodeTerminatorsMutex.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown node terminator %q, known are %v", name, NodeTerminatorNames())
+	}
+
+	return factory(client, options)
+}
+
+// simulatedCrashTaintKey marks nodes crashed by the simulate provider
+const simulatedCrashTaintKey = "chaos.arangodb.com/crashed"
+
+// simulateNodeTerminator simulates the loss of a node within kubernetes. The
+// node is cordoned and tainted with NoExecute, all its pods are force deleted
+// and optionally the Node object is removed.
+type simulateNodeTerminator struct {
+	client     k8s.Interface
+	deleteNode bool
+}
+
+func newSimulateNodeTerminator(client k8s.Interface, options NodeTerminatorOptions) (NodeTerminator, error) {
+	return &simulateNodeTerminator{
+		client:     client,
+		deleteNode: options.DeleteNode,
+	}, nil
+}
+
+// updateNodeTaints replaces the taints of the node using the given function
+func updateNodeTaints(client k8s.Interface, name string, update func([]v1.Taint) []v1.Taint) error {
+	return k8sretry.RetryOnConflict(k8sretry.DefaultRetry, func() error {
+		node, err := client.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		node.Spec.Taints = update(node.Spec.Taints)
+		_, err = client.CoreV1().Nodes().Update(node)
+		return err
+	})
+}
+
+func (t *simulateNodeTerminator) Terminate(ctx context.Context, name string) error {
+	log.Printf("Tainting node %s with %s", name, simulatedCrashTaintKey)
+	if err := updateNodeTaints(t.client, name, func(taints []v1.Taint) []v1.Taint {
+		for _, taint := range taints {
+			if taint.Key == simulatedCrashTaintKey {
+				return taints
+			}
+		}
+		return append(taints, v1.Taint{Key: simulatedCrashTaintKey, Effect: v1.TaintEffectNoExecute})
+	}); err != nil {
+		return errors.Wrap(err, "failed to taint node")
+	}
+
+	gracePeriod := int64(0)
+	if err := simulateCrashNode(ctx, t.client, name, &metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}); err != nil {
+		return err
+	}
+
+	if t.deleteNode {
+		log.Printf("Deleting node %s", name)
+		if err := t.client.CoreV1().Nodes().Delete(name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete node")
+		}
+	}
+
+	return nil
+}
+
+// Restore removes the taint and uncordons the node. A deleted node can not
+// be restored, it reappears once its kubelet registers again.
+func (t *simulateNodeTerminator) Restore(ctx context.Context, name string) error {
+	if err := updateNodeTaints(t.client, name, func(taints []v1.Taint) []v1.Taint {
+		var result []v1.Taint
+		for _, taint := range taints {
+			if taint.Key != simulatedCrashTaintKey {
+				result = append(result, taint)
+			}
+		}
+		return result
+	}); apierrors.IsNotFound(err) {
+		log.Printf("Node %s was deleted and can not be restored", name)
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to remove taint")
+	}
+
+	return uncordonNode(t.client, name)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"testing"
+
+	k8s "k8s.io/client-go/kubernetes"
+)
+
+// fakeNodeTerminator only records which nodes are terminated
+type fakeNodeTerminator struct {
+	mutex      sync.Mutex
+	terminated map[string]bool
+}
+
+func newFakeNodeTerminator() *fakeNodeTerminator {
+	return &fakeNodeTerminator{
+		terminated: make(map[string]bool),
+	}
+}
+
+func (t *fakeNodeTerminator) Terminate(ctx context.Context, name string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	log.Printf("Fake termination of node %s", name)
+	t.terminated[name] = true
+	return nil
+}
+
+func (t *fakeNodeTerminator) Restore(ctx context.Context, name string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if !t.terminated[name] {
+		return fmt.Errorf("node %s was not terminated", name)
+	}
+	log.Printf("Fake restore of node %s", name)
+	delete(t.terminated, name)
+	return nil
+}
+
+// IsTerminated returns true if the node is currently terminated
+func (t *fakeNodeTerminator) IsTerminated(name string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.terminated[name]
+}
+
+func TestRegisterNodeTerminator(t *testing.T) {
+	var created *fakeNodeTerminator
+	RegisterNodeTerminator("test", func(client k8s.Interface, options NodeTerminatorOptions) (NodeTerminator, error) {
+		created = newFakeNodeTerminator()
+		return created, nil
+	})
+	defer func() {
+		nodeTerminatorsMutex.Lock()
+		delete(nodeTerminators, "test")
+		nodeTerminatorsMutex.Unlock()
+	}()
+
+	if !containsString(NodeTerminatorNames(), "test") {
+		t.Fatalf("registered provider missing in %v", NodeTerminatorNames())
+	}
+
+	terminator, err := NewNodeTerminator("test", nil, NodeTerminatorOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if terminator != created {
+		t.Fatalf("terminator not created by the registered factory")
+	}
+
+	if _, err := NewNodeTerminator("unknown", nil, NodeTerminatorOptions{}); err == nil {
+		t.Fatalf("expected error for unknown provider")
+	}
+}
+
+func TestNodeKillRestore(t *testing.T) {
+	fake := newFakeNodeTerminator()
+	nodes, err := NewNodeManager(nil, fake, TargetSelector{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx := context.Background()
+	node := nodes.Node("node-1")
+	if err := node.Kill(ctx); err != nil {
+		t.Fatalf("kill failed: %s", err)
+	}
+	if !fake.IsTerminated("node-1") {
+		t.Errorf("node-1 not terminated after kill")
+	}
+	if fake.IsTerminated("node-2") {
+		t.Errorf("node-2 terminated without kill")
+	}
+
+	if err := node.Restore(ctx); err != nil {
+		t.Fatalf("restore failed: %s", err)
+	}
+	if fake.IsTerminated("node-1") {
+		t.Errorf("node-1 still terminated after restore")
+	}
+
+	if err := node.Restore(ctx); err == nil {
+		t.Errorf("expected error restoring a node that is not terminated")
+	}
+}